@@ -0,0 +1,115 @@
+package converter
+
+import (
+	"fmt"
+	"log"
+
+	badger "github.com/dgraph-io/badger"
+	"github.com/dimus/gnidump/util"
+	"github.com/gnames/uuid5"
+)
+
+// versionKey keeps the versions of gnparser and of ParsedName encoding that
+// were used to create the cache.
+var versionKey = []byte("parser_version")
+
+// parseCache keeps parsed names between runs of the converter, so only
+// names that are new to gni are parsed. Names are stored under their hash,
+// a UUID v5 of a name-string, and are reused only if they were parsed by
+// the current version of gnparser.
+type parseCache struct {
+	db      *badger.DB
+	version string
+}
+
+// newParseCache opens the parse cache and cleans it up if it was created
+// by a different version of gnparser, or if it keeps an older version of
+// ParsedName that lacks some of parsing results.
+func newParseCache(version string) *parseCache {
+	return openParseCache(util.InitParseCache(), version)
+}
+
+// openParseCache makes a parse cache from an open key-value store.
+func openParseCache(db *badger.DB, version string) *parseCache {
+	cachedVersion := cacheVersion(db)
+	currentVersion := fmt.Sprintf("%s|%d", version, util.EncodingVersion)
+	if cachedVersion != currentVersion {
+		log.Printf("Cache version changed from '%s' to '%s', resetting cache\n",
+			cachedVersion, currentVersion)
+		err := db.DropAll()
+		util.Check(err)
+		err = db.Update(func(txn *badger.Txn) error {
+			return txn.Set(versionKey, []byte(currentVersion))
+		})
+		util.Check(err)
+	}
+	return &parseCache{db: db, version: version}
+}
+
+func cacheVersion(db *badger.DB) string {
+	var version []byte
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(versionKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		version, err = item.ValueCopy(nil)
+		return err
+	})
+	util.Check(err)
+	return string(version)
+}
+
+func (pc *parseCache) close() {
+	err := pc.db.Close()
+	util.Check(err)
+}
+
+// nameHash returns a key of a name-string in the cache.
+func nameHash(name string) []byte {
+	return []byte(uuid5.UUID5(name).String())
+}
+
+// get returns cached parsed names, keyed by their name-strings.
+func (pc *parseCache) get(names []string) map[string]util.ParsedName {
+	res := make(map[string]util.ParsedName)
+	err := pc.db.View(func(txn *badger.Txn) error {
+		for _, name := range names {
+			item, err := txn.Get(nameHash(name))
+			if err == badger.ErrKeyNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			pn, err := util.Decode(val)
+			if err != nil {
+				return err
+			}
+			if pn.ParserVersion == pc.version && pn.Name == name {
+				res[name] = pn
+			}
+		}
+		return nil
+	})
+	util.Check(err)
+	return res
+}
+
+// set saves parsed names to the cache. Original gni IDs are not cached, as
+// they belong to a particular dump of gni.
+func (pc *parseCache) set(parsedNames []util.ParsedName) {
+	wb := pc.db.NewWriteBatch()
+	for _, pn := range parsedNames {
+		pn.IDOriginal = ""
+		err := wb.Set(nameHash(pn.Name), pn.Encode())
+		util.Check(err)
+	}
+	err := wb.Flush()
+	util.Check(err)
+}
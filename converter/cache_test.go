@@ -0,0 +1,72 @@
+package converter
+
+import (
+	"strconv"
+	"testing"
+
+	badger "github.com/dgraph-io/badger"
+
+	"github.com/dimus/gnidump/util"
+)
+
+func TestParseCacheVersion(t *testing.T) {
+	kv := testKV(t)
+	pn := util.ParsedName{ID: "uuid-1", Name: "Aus bus", ParserVersion: "v1"}
+
+	cache := openParseCache(kv, "v1")
+	cache.set([]util.ParsedName{pn})
+	if len(cache.get([]string{"Aus bus"})) != 1 {
+		t.Fatal("name is not cached")
+	}
+
+	cache = openParseCache(kv, "v1")
+	if len(cache.get([]string{"Aus bus"})) != 1 {
+		t.Error("cache was dropped without a change of version")
+	}
+
+	cache = openParseCache(kv, "v2")
+	if cacheVersion(kv) != "v2|"+strconv.Itoa(int(util.EncodingVersion)) {
+		t.Errorf("wrong cache version %s", cacheVersion(kv))
+	}
+	if isStored(nameHash("Aus bus"), kv) {
+		t.Error("cache was not dropped after a change of version")
+	}
+}
+
+func TestParseCacheGet(t *testing.T) {
+	kv := testKV(t)
+	cache := openParseCache(kv, "v1")
+	cache.set([]util.ParsedName{
+		{ID: "uuid-1", Name: "Aus bus", ParserVersion: "v1"},
+		{ID: "uuid-2", Name: "Aus cus", ParserVersion: "v0"},
+	})
+	// an entry saved under the hash of another name
+	wrong := util.ParsedName{ID: "uuid-3", Name: "Bus bus", ParserVersion: "v1"}
+	err := kv.Update(func(txn *badger.Txn) error {
+		return txn.Set(nameHash("Aus dus"), wrong.Encode())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := cache.get([]string{"Aus bus", "Aus cus", "Aus dus", "Cus cus"})
+	if len(res) != 1 || res["Aus bus"].ID != "uuid-1" {
+		t.Errorf("got %+v, want only 'Aus bus'", res)
+	}
+}
+
+func TestParseCacheSet(t *testing.T) {
+	kv := testKV(t)
+	cache := openParseCache(kv, "v1")
+	cache.set([]util.ParsedName{
+		{ID: "uuid-1", IDOriginal: "123", Name: "Aus bus", ParserVersion: "v1"},
+	})
+
+	pn, ok := cache.get([]string{"Aus bus"})["Aus bus"]
+	if !ok {
+		t.Fatal("name is not cached")
+	}
+	if pn.IDOriginal != "" {
+		t.Errorf("gni ID %s is cached", pn.IDOriginal)
+	}
+}
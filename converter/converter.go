@@ -4,45 +4,159 @@ package converter
 
 import (
 	"encoding/csv"
+	"io"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 
 	badger "github.com/dgraph-io/badger"
 	"github.com/dimus/gnidump/util"
 	"github.com/gnames/uuid5"
-	"gitlab.com/gogna/gnparser"
+	"gitlab.com/gogna/gnparser/pb"
 )
 
+// parsingJob is a chunk of name_strings.csv. Offset is the number of
+// records read before the chunk. Names map name-strings to their gni IDs,
+// as the same name-string might appear in gni several times.
+type parsingJob struct {
+	Offset int
+	Names  map[string][]string
+}
+
+// nameStore saves parsed names to the key-value store and reports
+// name-strings that have more than one gni ID.
+type nameStore struct {
+	sync.Mutex
+	kv         *badger.DB
+	duplicates *util.Report
+}
+
 // Data fetches data needed for gnindex and stores it in a key-value store.
-func Data() {
-	parsingJobs := make(chan map[string]string, 100)
+// Names parsed by previous runs with the same version of gnparser are taken
+// from the parse cache. If resume is true, the key-value store is not
+// cleaned up, chunks finished by a previous run are skipped, and names
+// already stored are not parsed again.
+func Data(resume bool) {
+	parsingJobs := make(chan parsingJob, 100)
 	var wg sync.WaitGroup
 
-	resetKV()
+	if resume {
+		log.Println("Resuming conversion")
+	} else {
+		resetKV()
+	}
 
 	kv := util.InitBadger()
 	defer kv.Close()
 
+	version, err := NewParser(util.EnvVars()["parser_url"]).Version()
+	util.Check(err)
+	cache := newParseCache(version)
+	defer cache.close()
+
+	duplicates := util.NewReport("duplicate_name_strings",
+		[]string{"name_uuid", "name", "name_string_id", "duplicate_of_id"},
+		resume)
+	defer duplicates.Close()
+	store := &nameStore{kv: kv, duplicates: duplicates}
+
+	parseReport := util.NewReport("parse_report",
+		[]string{"name_string_id", "name", "quality", "warnings", "name_type"},
+		resume)
+	defer parseReport.Close()
+
+	stats := &nameTypeStats{counts: make(map[util.NameType]int)}
+
 	for i := 1; i <= util.WorkersNum(); i++ {
 		wg.Add(1)
-		go parserWorker(i, parsingJobs, &wg, kv)
+		go parserWorker(i, parsingJobs, &wg, store, cache, parseReport, stats)
 	}
 
-	go prepareJobs(parsingJobs)
+	chunks := make(chan [][]string)
+	go ReadCSVNameStrings(chunks, ChunkSize)
+	go prepareJobs(chunks, parsingJobs, resume, kv)
 
 	wg.Wait()
+	stats.log()
 }
 
-// ReadCSVNameStrings reads all lines from gni's name_strings.csv into memory.
-func ReadCSVNameStrings() [][]string {
+// nameTypeStats counts name-strings of every type processed by a run of
+// the converter.
+type nameTypeStats struct {
+	sync.Mutex
+	counts map[util.NameType]int
+}
+
+// add counts each gni ID of parsed names.
+func (st *nameTypeStats) add(parsedNames []util.ParsedName,
+	names map[string][]string) {
+	st.Lock()
+	defer st.Unlock()
+	for _, v := range parsedNames {
+		st.counts[v.NameType] += len(names[v.Name])
+	}
+}
+
+func (st *nameTypeStats) log() {
+	total := 0
+	log.Println("Name-strings by type:")
+	for _, nt := range util.NameTypes() {
+		log.Printf("  %-15s %d\n", nt, st.counts[nt])
+		total += st.counts[nt]
+	}
+	log.Printf("  %-15s %d\n", "total", total)
+}
+
+// ChunkSize is the number of CSV records in one batch of work.
+const ChunkSize = 10000
+
+// ReadCSVNameStrings streams records from gni's name_strings.csv to chunks
+// in batches of chunkSize. The header is skipped and the channel is closed
+// after the last batch, so only a few batches are kept in memory at a time.
+func ReadCSVNameStrings(chunks chan<- [][]string, chunkSize int) {
 	log.Println("Getting name_strings from CSV file")
 	f := GniFile("name_strings")
-	r := csv.NewReader(f)
-	records, err := r.ReadAll()
+	defer f.Close()
+	err := ReadCSVChunks(f, chunks, chunkSize)
 	util.Check(err)
-	return records
+}
+
+// ReadCSVChunks reads CSV records from r and sends them to chunks in batches
+// of chunkSize. The first record is treated as a header and skipped. The
+// channel is closed when reading is finished.
+func ReadCSVChunks(r io.Reader, chunks chan<- [][]string,
+	chunkSize int) error {
+	defer close(chunks)
+	cr := csv.NewReader(r)
+
+	//skip header
+	_, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, chunkSize)
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+		if len(rows) == chunkSize {
+			chunks <- rows
+			rows = make([][]string, 0, chunkSize)
+		}
+	}
+	if len(rows) > 0 {
+		chunks <- rows
+	}
+	return nil
 }
 
 // GniFile returns handles to existing CSV files with gni dumps.
@@ -57,25 +171,38 @@ func resetKV() {
 	util.CleanDir(util.BadgerDir)
 }
 
-func parserWorker(id int, parsingJobs <-chan map[string]string,
-	wg *sync.WaitGroup, kv *badger.DB) {
-	gnp := gnparser.NewGNparser()
+func parserWorker(id int, parsingJobs <-chan parsingJob,
+	wg *sync.WaitGroup, store *nameStore, cache *parseCache,
+	parseReport *util.Report, stats *nameTypeStats) {
+	p := NewParser(util.EnvVars()["parser_url"])
 	defer wg.Done()
 	for {
 		j, more := <-parsingJobs
 		if more {
-			parsedNames := parseNamesBatch(gnp, j)
-			storeParsedNames(&parsedNames, kv)
+			parsedNames := parseNamesBatch(p, j.Names, cache)
+			reportPoorQuality(parsedNames, j.Names, parseReport)
+			stats.add(parsedNames, j.Names)
+			store.save(parsedNames, j)
 		} else {
 			return
 		}
 	}
 }
 
-func storeParsedNames(parsedNames *[]util.ParsedName, kv *badger.DB) {
+// save stores parsed names together with the offset of their chunk, so the
+// chunk is marked as finished only if all its names are saved. A parsed
+// name is saved once, and all gni IDs of its name-string point to it. Only
+// one worker saves names at a time, so duplicates from different chunks are
+// always found.
+func (ns *nameStore) save(parsedNames []util.ParsedName, j parsingJob) {
+	ns.Lock()
+	defer ns.Unlock()
+
 	var err error
-	entries := badgerize(parsedNames)
-	wb := kv.NewWriteBatch()
+	entries := ns.badgerize(parsedNames, j.Names)
+	entries = append(entries,
+		&badger.Entry{Key: chunkKey(j.Offset), Value: []byte{}})
+	wb := ns.kv.NewWriteBatch()
 	for _, v := range entries {
 		err = wb.SetEntry(v)
 		if err != nil {
@@ -88,42 +215,136 @@ func storeParsedNames(parsedNames *[]util.ParsedName, kv *badger.DB) {
 	}
 }
 
-func badgerize(parsedNames *[]util.ParsedName) []*badger.Entry {
-	batchSize := len(*parsedNames) * 2
-	var entries = make([]*badger.Entry, batchSize)
-	for i, v := range *parsedNames {
-		encodedParsedName := v.EncodeGob()
-		e1 := badger.Entry{Key: []byte(v.ID), Value: encodedParsedName.Bytes()}
-		e2 := badger.Entry{Key: []byte(v.IDOriginal),
-			Value: encodedParsedName.Bytes()}
-		index := i * 2
-		entries[index] = &e1
-		entries[index+1] = &e2
+// poorQuality is the parsing quality of names with serious problems.
+// Names that were not parsed at all have quality 0.
+const poorQuality = 3
+
+// reportPoorQuality adds names that were not parsed, or were parsed with
+// serious problems, to the parse report. A name-string is reported for each
+// of its gni IDs.
+func reportPoorQuality(parsedNames []util.ParsedName,
+	names map[string][]string, parseReport *util.Report) {
+	for _, v := range parsedNames {
+		if v.Quality != 0 && v.Quality < poorQuality {
+			continue
+		}
+		for _, id := range names[v.Name] {
+			parseReport.Write([]string{id, v.Name, strconv.Itoa(v.Quality),
+				strings.Join(v.Warnings, "|"), v.NameType.String()})
+		}
+	}
+}
+
+func chunkKey(offset int) []byte {
+	return []byte("chunk|" + strconv.Itoa(offset))
+}
+
+// isStored checks if a key exists in the key-value store.
+func isStored(key []byte, kv *badger.DB) bool {
+	txn := kv.NewTransaction(false)
+	defer txn.Discard()
+	_, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return false
+	}
+	util.Check(err)
+	return true
+}
+
+// badgerize creates an entry with the encoded ParsedName under its UUID, and
+// entries that map gni IDs of the name-string to the UUID. If the UUID is
+// already stored, the name-string is reported as a duplicate.
+func (ns *nameStore) badgerize(parsedNames []util.ParsedName,
+	names map[string][]string) []*badger.Entry {
+	entries := make([]*badger.Entry, 0, len(parsedNames)*2)
+	for _, v := range parsedNames {
+		ids := names[v.Name]
+		firstID := v.IDOriginal
+		if stored, ok := ns.storedName(v.ID); ok {
+			firstID = stored.IDOriginal
+		} else {
+			entries = append(entries,
+				&badger.Entry{Key: util.NameKey(v.ID), Value: v.Encode()})
+		}
+		for _, id := range ids {
+			if id != firstID {
+				ns.duplicates.Write([]string{v.ID, v.Name, id, firstID})
+			}
+			entries = append(entries, &badger.Entry{Key: util.OriginalKey(id),
+				Value: []byte(v.ID)})
+		}
 	}
 	return entries
 }
 
-func parseNamesBatch(gnp gnparser.GNparser,
-	namesMap map[string]string) []util.ParsedName {
-	parsedNames := make([]util.ParsedName, len(namesMap))
-	count := 0
-	for name, id := range namesMap {
-		parsed := parseName(gnp, name, id)
-		parsedNames[count] = parsed
-		count++
+func (ns *nameStore) storedName(uuid string) (util.ParsedName, bool) {
+	txn := ns.kv.NewTransaction(false)
+	defer txn.Discard()
+	item, err := txn.Get(util.NameKey(uuid))
+	if err == badger.ErrKeyNotFound {
+		return util.ParsedName{}, false
 	}
-	log.Printf("Parsed '%s'\n", parsedNames[0].Canonical)
+	util.Check(err)
+	val, err := item.ValueCopy(nil)
+	util.Check(err)
+	pn, err := util.Decode(val)
+	util.Check(err)
+	return pn, true
+}
+
+// parseNamesBatch parses names that are not in the parse cache and adds
+// them to the cache.
+func parseNamesBatch(p Parser, namesMap map[string][]string,
+	cache *parseCache) []util.ParsedName {
+	names := make([]string, 0, len(namesMap))
+	for name := range namesMap {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return []util.ParsedName{}
+	}
+
+	cached := cache.get(names)
+	newNames := make([]string, 0, len(names)-len(cached))
+	for _, name := range names {
+		if _, ok := cached[name]; !ok {
+			newNames = append(newNames, name)
+		}
+	}
+
+	parsedNames := make([]util.ParsedName, 0, len(names))
+	if len(newNames) > 0 {
+		parsed, err := p.ParseBatch(newNames)
+		util.Check(err)
+		for i, name := range newNames {
+			parsedNames = append(parsedNames,
+				parseName(parsed[i], name, namesMap[name][0]))
+		}
+		cache.set(parsedNames)
+	}
+	for name, pn := range cached {
+		pn.IDOriginal = namesMap[name][0]
+		parsedNames = append(parsedNames, pn)
+	}
+	log.Printf("Parsed '%s', %d names from cache\n", parsedNames[0].Canonical,
+		len(cached))
 	return parsedNames
 }
 
-func parseName(gnp gnparser.GNparser, name, origID string) util.ParsedName {
-	p := gnp.ParseToObject(name)
-	var canonical, canonicalWithRank, idCanonical string
+func parseName(p *pb.Parsed, name, origID string) util.ParsedName {
+	var canonical, canonicalWithRank, canonicalStem, idCanonical string
 	if p.Canonical != nil {
 		canonical = p.Canonical.Simple
 		canonicalWithRank = p.Canonical.Full
+		canonicalStem = p.Canonical.Stem
 		idCanonical = uuid5.UUID5(p.Canonical.Simple).String()
 	}
+	var warnings []string
+	for _, v := range p.QualityWarning {
+		warnings = append(warnings, v.Message)
+	}
+	authorship, year := authorshipYear(p.Authorship)
+	nameType := util.NewNameType(p)
 	return util.ParsedName{
 		ID:                p.Id,
 		IDCanonical:       idCanonical,
@@ -131,35 +352,93 @@ func parseName(gnp gnparser.GNparser, name, origID string) util.ParsedName {
 		Name:              name,
 		Canonical:         canonical,
 		CanonicalWithRank: canonicalWithRank,
-		Surrogate:         isSurrogate(p.NameType.String()),
+		Surrogate:         nameType.IsSurrogate(),
 		Positions:         p.Positions,
+		ParserVersion:     p.ParserVersion,
+		Quality:           int(p.Quality),
+		Warnings:          warnings,
+		NameType:          nameType,
+		Authorship:        authorship,
+		Year:              year,
+		Rank:              rank(p, name),
+		Hybrid:            p.Hybrid,
+		Virus:             nameType == util.Virus,
+		CanonicalStem:     canonicalStem,
 	}
 }
 
-func isSurrogate(s string) bool {
-	return strings.HasSuffix(s, "SURROGATE")
+// authorshipYear returns authorship of the most specific element of a name
+// and the year of its original description. If the year of the original
+// authorship is not given, the year of the combination is used.
+func authorshipYear(au *pb.Authorship) (string, string) {
+	if au == nil {
+		return "", ""
+	}
+	var year string
+	if au.Original != nil {
+		year = au.Original.Year
+	}
+	if year == "" && au.Combination != nil {
+		year = au.Combination.Year
+	}
+	return au.Value, year
 }
 
-func prepareJobs(parsingJobs chan<- map[string]string) {
-	records := ReadCSVNameStrings()
+// rank returns the rank of the most specific element of a name, if it is
+// given. Ranks are taken from word positions, because gnparser does not keep
+// infraspecific details in its protobuf output.
+func rank(p *pb.Parsed, name string) string {
+	words := []rune(name)
+	for i := len(p.Positions) - 1; i >= 0; i-- {
+		v := p.Positions[i]
+		if v.Type == "rank" && int(v.End) <= len(words) {
+			return string(words[v.Start:v.End])
+		}
+	}
+	return ""
+}
 
+// prepareJobs turns chunks of name_strings.csv into parsing jobs. If resume
+// is true, names stored by a previous run are left out.
+func prepareJobs(chunks <-chan [][]string, parsingJobs chan<- parsingJob,
+	resume bool, kv *badger.DB) {
 	log.Println("Getting names parsed")
-	totalSize := len(records)
-	chunkSize := 10000
-	for i := 1; i < totalSize; i += chunkSize {
-		end := i + chunkSize
-		if end > totalSize {
-			end = totalSize
+	offset := 0
+	for records := range chunks {
+		if resume {
+			records = unstoredRecords(records, offset, kv)
+		}
+		if records != nil {
+			parsingJobs <- parsingJob{Offset: offset, Names: namesMap(records)}
 		}
-		parsingJobs <- namesMap(records[i:end])
+		offset += ChunkSize
 	}
 	close(parsingJobs)
 }
 
-func namesMap(records [][]string) map[string]string {
-	res := make(map[string]string)
+// unstoredRecords returns records of a chunk that are not yet in the
+// key-value store. It returns nil if the chunk was finished by a previous
+// run.
+func unstoredRecords(records [][]string, offset int,
+	kv *badger.DB) [][]string {
+	if isStored(chunkKey(offset), kv) {
+		log.Printf("Skipping finished chunk at %d\n", offset)
+		return nil
+	}
+	res := make([][]string, 0, len(records))
+	for _, record := range records {
+		if !isStored(util.OriginalKey(record[0]), kv) {
+			res = append(res, record)
+		}
+	}
+	return res
+}
+
+// namesMap groups gni IDs of records by their name-strings.
+func namesMap(records [][]string) map[string][]string {
+	res := make(map[string][]string)
 	for _, record := range records {
-		res[record[1]] = record[0]
+		res[record[1]] = append(res[record[1]], record[0])
 	}
 	return res
 }
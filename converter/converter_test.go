@@ -0,0 +1,193 @@
+package converter
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	badger "github.com/dgraph-io/badger"
+	"github.com/dgraph-io/badger/options"
+	"github.com/dimus/gnidump/util"
+)
+
+// testKV opens a small key-value store in a temporary directory.
+func testKV(t *testing.T) *badger.DB {
+	opts := badger.DefaultOptions(t.TempDir()).WithLogger(nil).
+		WithTableLoadingMode(options.LoadToRAM).
+		WithValueLogLoadingMode(options.FileIO).
+		WithValueLogFileSize(1 << 20)
+	kv, err := badger.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { kv.Close() })
+	return kv
+}
+
+// setKeys saves empty values under the given keys.
+func setKeys(t *testing.T, kv *badger.DB, keys ...[]byte) {
+	wb := kv.NewWriteBatch()
+	for _, k := range keys {
+		if err := wb.Set(k, []byte{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runJobs sends chunks to prepareJobs and collects the parsing jobs.
+func runJobs(kv *badger.DB, resume bool, chunks ...[][]string) []parsingJob {
+	chunksCh := make(chan [][]string)
+	go func() {
+		for _, c := range chunks {
+			chunksCh <- c
+		}
+		close(chunksCh)
+	}()
+	jobs := make(chan parsingJob, len(chunks))
+	prepareJobs(chunksCh, jobs, resume, kv)
+	var res []parsingJob
+	for j := range jobs {
+		res = append(res, j)
+	}
+	return res
+}
+
+func TestReadCSVChunks(t *testing.T) {
+	csv := "id,name\n1,Aus\n2,Bus\n3,Cus\n4,Dus\n5,Eus\n"
+	chunks := make(chan [][]string)
+	go func() {
+		err := ReadCSVChunks(strings.NewReader(csv), chunks, 2)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var sizes []int
+	var ids []string
+	for c := range chunks {
+		sizes = append(sizes, len(c))
+		for _, row := range c {
+			ids = append(ids, row[0])
+		}
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[2] != 1 {
+		t.Errorf("wrong chunk sizes %v", sizes)
+	}
+	if strings.Join(ids, ",") != "1,2,3,4,5" {
+		t.Errorf("wrong records %v", ids)
+	}
+}
+
+func TestResumeSkipsFinishedChunk(t *testing.T) {
+	kv := testKV(t)
+	setKeys(t, kv, chunkKey(0))
+	chunk1 := [][]string{{"1", "Aus bus"}, {"2", "Aus cus"}}
+	chunk2 := [][]string{{"3", "Bus bus"}}
+
+	jobs := runJobs(kv, true, chunk1, chunk2)
+	if len(jobs) != 1 || jobs[0].Offset != ChunkSize {
+		t.Fatalf("got jobs %+v, want only the chunk at %d", jobs, ChunkSize)
+	}
+	if ids := jobs[0].Names["Bus bus"]; len(ids) != 1 || ids[0] != "3" {
+		t.Errorf("wrong names %v", jobs[0].Names)
+	}
+
+	jobs = runJobs(kv, false, chunk1, chunk2)
+	if len(jobs) != 2 {
+		t.Errorf("got %d jobs without resume, want 2", len(jobs))
+	}
+}
+
+func TestResumePartlyStoredChunk(t *testing.T) {
+	kv := testKV(t)
+	setKeys(t, kv, util.OriginalKey("1"), util.OriginalKey("3"))
+	chunk := [][]string{{"1", "Aus bus"}, {"2", "Aus cus"}, {"3", "Aus bus"},
+		{"4", "Bus bus"}}
+
+	jobs := runJobs(kv, true, chunk)
+	if len(jobs) != 1 {
+		t.Fatalf("got %d jobs, want 1", len(jobs))
+	}
+	names := jobs[0].Names
+	if len(names) != 2 || names["Aus cus"][0] != "2" ||
+		names["Bus bus"][0] != "4" {
+		t.Errorf("got names %v, want only IDs 2 and 4", names)
+	}
+}
+
+func TestResumeWritesChunkKey(t *testing.T) {
+	kv := testKV(t)
+	setKeys(t, kv, util.OriginalKey("1"))
+	chunk := [][]string{{"1", "Aus bus"}, {"2", "Aus cus"}}
+	store := &nameStore{kv: kv, duplicates: util.NewReportFile(
+		t.TempDir()+"/duplicates.csv", nil)}
+	defer store.duplicates.Close()
+
+	jobs := runJobs(kv, true, chunk)
+	if len(jobs) != 1 {
+		t.Fatalf("got %d jobs, want 1", len(jobs))
+	}
+	pn := util.ParsedName{ID: "uuid-2", IDOriginal: "2", Name: "Aus cus"}
+	store.save([]util.ParsedName{pn}, jobs[0])
+
+	for _, k := range [][]byte{chunkKey(0), util.OriginalKey("2"),
+		util.NameKey("uuid-2")} {
+		if !isStored(k, kv) {
+			t.Errorf("key %s is not stored", k)
+		}
+	}
+	if jobs = runJobs(kv, true, chunk); len(jobs) != 0 {
+		t.Errorf("finished chunk was not skipped: %+v", jobs)
+	}
+}
+
+func TestDuplicatesAcrossChunks(t *testing.T) {
+	kv := testKV(t)
+	path := filepath.Join(t.TempDir(), "duplicate_name_strings.csv")
+	store := &nameStore{kv: kv, duplicates: util.NewReportFile(path, nil)}
+	aus := util.ParsedName{ID: "uuid-1", IDOriginal: "1", Name: "Aus bus"}
+	bus := util.ParsedName{ID: "uuid-2", IDOriginal: "2", Name: "Bus bus"}
+
+	store.save([]util.ParsedName{aus, bus}, parsingJob{Offset: 0,
+		Names: map[string][]string{"Aus bus": {"1"}, "Bus bus": {"2"}}})
+	aus.IDOriginal = "5"
+	bus.IDOriginal = "6"
+	store.save([]util.ParsedName{aus, bus}, parsingJob{Offset: ChunkSize,
+		Names: map[string][]string{"Aus bus": {"5"}, "Bus bus": {"6", "7"}}})
+	store.duplicates.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []string
+	for _, row := range rows {
+		res = append(res, strings.Join(row, ","))
+	}
+	want := []string{"uuid-1,Aus bus,5,1", "uuid-2,Bus bus,6,2",
+		"uuid-2,Bus bus,7,2"}
+	if strings.Join(res, "|") != strings.Join(want, "|") {
+		t.Errorf("got duplicates %v, want %v", res, want)
+	}
+
+	// the name is stored once, under its first gni ID
+	pn, ok := store.storedName("uuid-1")
+	if !ok || pn.IDOriginal != "1" {
+		t.Errorf("got stored name %+v, want gni ID 1", pn)
+	}
+	for _, id := range []string{"1", "2", "5", "6", "7"} {
+		if !isStored(util.OriginalKey(id), kv) {
+			t.Errorf("gni ID %s is not stored", id)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package converter
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dimus/gnidump/util"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// word is a word of a name-string with its semantic meaning.
+type word struct {
+	Type  string
+	Value string
+}
+
+// parseOutput is a ParsedName together with the words of its name-string.
+type parseOutput struct {
+	util.ParsedName
+	Words []word
+}
+
+// Parse parses names the same way Data does and writes the resulting
+// ParsedName objects to w. The format is either "json" or "csv".
+func Parse(names []string, format string, w io.Writer) error {
+	if format != "json" && format != "csv" {
+		return fmt.Errorf("unknown format '%s'", format)
+	}
+	p := NewParser(util.EnvVars()["parser_url"])
+	parsed, err := p.ParseBatch(names)
+	if err != nil {
+		return err
+	}
+
+	res := make([]parseOutput, len(names))
+	for i, name := range names {
+		pn := parseName(parsed[i], name, "")
+		res[i] = parseOutput{ParsedName: pn, Words: words(pn)}
+	}
+
+	if format == "json" {
+		return writeParseJSON(res, w)
+	}
+	return writeParseCSV(res, w)
+}
+
+// NamesFromFile reads names from a file, one name per line. Empty lines are
+// ignored.
+func NamesFromFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		name := strings.TrimSpace(sc.Text())
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, sc.Err()
+}
+
+func words(pn util.ParsedName) []word {
+	name := []rune(pn.Name)
+	res := make([]word, len(pn.Positions))
+	for i, v := range pn.Positions {
+		res[i] = word{Type: v.Type, Value: string(name[v.Start:v.End])}
+	}
+	return res
+}
+
+func writeParseJSON(res []parseOutput, w io.Writer) error {
+	bs, err := jsoniter.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(bs))
+	return err
+}
+
+func writeParseCSV(res []parseOutput, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"id", "name", "canonical_uuid", "canonical",
+		"canonical_ranked", "canonical_stem", "surrogate", "authorship", "year",
+		"rank", "quality", "warnings", "name_type", "hybrid", "virus",
+		"parser_version", "words"})
+	if err != nil {
+		return err
+	}
+	for _, v := range res {
+		ws := make([]string, len(v.Words))
+		for i, w := range v.Words {
+			ws[i] = w.Type + ":" + w.Value
+		}
+		pn := v.ParsedName
+		err = cw.Write([]string{pn.ID, pn.Name, pn.IDCanonical, pn.Canonical,
+			pn.CanonicalWithRank, pn.CanonicalStem,
+			strconv.FormatBool(pn.Surrogate), pn.Authorship, pn.Year, pn.Rank,
+			strconv.Itoa(pn.Quality),
+			strings.Join(pn.Warnings, "|"), pn.NameType.String(),
+			strconv.FormatBool(pn.Hybrid), strconv.FormatBool(pn.Virus),
+			pn.ParserVersion, strings.Join(ws, "|")})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,38 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	var b bytes.Buffer
+	err := Parse([]string{"Abies alba var. alba Mill."}, "csv", &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	res := make(map[string]string)
+	for i, v := range rows[0] {
+		res[v] = rows[1][i]
+	}
+	if res["canonical"] != "Abies alba alba" || res["rank"] != "var." ||
+		res["authorship"] != "Mill." || res["name_type"] != "infraspecific" {
+		t.Errorf("wrong parsing result %v", res)
+	}
+	if res["words"] != "genus:Abies|specificEpithet:alba|rank:var.|"+
+		"infraspecificEpithet:alba|authorWord:Mill." {
+		t.Errorf("wrong words %s", res["words"])
+	}
+
+	if err = Parse([]string{"Aus"}, "xml", &b); err == nil {
+		t.Error("expected an error for unknown format")
+	}
+}
@@ -0,0 +1,223 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"gitlab.com/gogna/gnparser"
+	"gitlab.com/gogna/gnparser/grammar"
+	"gitlab.com/gogna/gnparser/output"
+	"gitlab.com/gogna/gnparser/pb"
+)
+
+// jsonStd follows encoding/json rules, so the fields of remoteOutput shadow
+// the fields of embedded output.Output.
+var jsonStd = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// remoteBatchSize is the maximum number of names sent to a gnparser web
+// service in one request.
+const remoteBatchSize = 1000
+
+// Parser converts name-strings into parsed objects. Results keep the same
+// order as the given names.
+type Parser interface {
+	ParseBatch(names []string) ([]*pb.Parsed, error)
+	Version() (string, error)
+}
+
+// NewParser returns a parser that sends names to a gnparser web service
+// located at url. If url is empty, names are parsed in-process.
+func NewParser(url string) Parser {
+	if url == "" {
+		return NewLocalParser()
+	}
+	return NewRemoteParser(url)
+}
+
+// LocalParser parses names using gnparser library.
+type LocalParser struct {
+	gnp gnparser.GNparser
+}
+
+// NewLocalParser creates an in-process parser.
+func NewLocalParser() *LocalParser {
+	return &LocalParser{gnp: gnparser.NewGNparser()}
+}
+
+// ParseBatch parses names one by one with gnparser.
+func (lp *LocalParser) ParseBatch(names []string) ([]*pb.Parsed, error) {
+	res := make([]*pb.Parsed, len(names))
+	for i, name := range names {
+		res[i] = lp.gnp.ParseToObject(name)
+	}
+	return res, nil
+}
+
+// Version returns the version of gnparser library.
+func (lp *LocalParser) Version() (string, error) {
+	return lp.gnp.Version(), nil
+}
+
+// RemoteParser parses names using a gnparser web service.
+type RemoteParser struct {
+	url    string
+	client *http.Client
+}
+
+// NewRemoteParser creates a client for a gnparser web service. The url should
+// point to the service's API endpoint, for example
+// "http://parser.globalnames.org/api".
+func NewRemoteParser(url string) *RemoteParser {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	return &RemoteParser{url: url, client: client}
+}
+
+// ParseBatch sends names to the web service in batches of remoteBatchSize.
+func (rp *RemoteParser) ParseBatch(names []string) ([]*pb.Parsed, error) {
+	res := make([]*pb.Parsed, 0, len(names))
+	for i := 0; i < len(names); i += remoteBatchSize {
+		end := i + remoteBatchSize
+		if end > len(names) {
+			end = len(names)
+		}
+		parsed, err := rp.post(names[i:end])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, parsed...)
+	}
+	return res, nil
+}
+
+// Version returns the version of gnparser used by the web service. The
+// service does not have a version endpoint, so the version is taken from
+// parsing results.
+func (rp *RemoteParser) Version() (string, error) {
+	parsed, err := rp.post([]string{"Homo sapiens"})
+	if err != nil {
+		return "", err
+	}
+	return parsed[0].ParserVersion, nil
+}
+
+func (rp *RemoteParser) post(names []string) ([]*pb.Parsed, error) {
+	body, err := jsoniter.Marshal(names)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rp.client.Post(rp.url, "application/json",
+		bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("parser service %s returned %s", rp.url,
+			resp.Status)
+	}
+
+	var outputs []remoteOutput
+	err = jsonStd.Unmarshal(data, &outputs)
+	if err != nil {
+		return nil, err
+	}
+	return matchOutputs(names, outputs, rp.url)
+}
+
+// matchOutputs puts results of the web service in the order of names. The
+// service returns results in the order they are parsed, and skips names
+// that caused errors, so results are matched to names by their verbatim
+// strings. If a name has no result, it returns an error.
+func matchOutputs(names []string, outputs []remoteOutput,
+	url string) ([]*pb.Parsed, error) {
+	parsed := make(map[string]*pb.Parsed, len(outputs))
+	for i := range outputs {
+		p, err := outputs[i].toPB()
+		if err != nil {
+			return nil, err
+		}
+		parsed[outputs[i].Verbatim] = p
+	}
+
+	res := make([]*pb.Parsed, len(names))
+	var missing []string
+	for i, name := range names {
+		p, ok := parsed[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		res[i] = p
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("parser service %s returned no results for %d "+
+			"names, for example '%s'", url, len(missing), missing[0])
+	}
+	return res, nil
+}
+
+// remoteOutput is JSON output of gnparser web service. Details are kept
+// raw to be decoded into gnparser's own types.
+type remoteOutput struct {
+	output.Output
+	Details []jsoniter.RawMessage `json:"details,omitempty"`
+}
+
+// toPB converts JSON output of the web service to the same object the
+// in-process parser returns.
+func (ro *remoteOutput) toPB() (*pb.Parsed, error) {
+	o := ro.Output
+	o.Details = make([]interface{}, 0, len(ro.Details))
+	for _, v := range ro.Details {
+		d, err := decodeDetails(v)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			o.Details = append(o.Details, d)
+		}
+	}
+	return pb.ToPB(&o), nil
+}
+
+// decodeDetails finds out the kind of details by their keys. Comparisons and
+// approximations have the same keys, comparisons are marked by 'cf'.
+func decodeDetails(data []byte) (interface{}, error) {
+	var keys map[string]jsoniter.RawMessage
+	err := jsonStd.Unmarshal(data, &keys)
+	if err != nil {
+		return nil, err
+	}
+	var d interface{}
+	var annot string
+	if v, ok := keys["annotationIdentification"]; ok {
+		err = jsonStd.Unmarshal(v, &annot)
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case keys["uninomial"] != nil:
+		d = &grammar.UninomialOutput{}
+	case strings.HasPrefix(annot, "cf"):
+		d = &grammar.ComparisonOutput{}
+	case annot != "":
+		d = &grammar.ApproxOutput{}
+	case keys["specificEpithet"] != nil:
+		d = &grammar.SpeciesOutput{}
+	default:
+		return nil, nil
+	}
+	err = jsonStd.Unmarshal(data, d)
+	return d, err
+}
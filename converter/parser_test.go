@@ -0,0 +1,123 @@
+package converter
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"gitlab.com/gogna/gnparser"
+)
+
+var testNames = []string{
+	"Homo sapiens Linnaeus, 1758",
+	"Pomatomus",
+	"Abies alba var. alba Mill.",
+	"Aus cf. bus",
+	"Bubo sp.",
+	"Tobacco mosaic virus",
+	"Salix alba × Salix fragilis",
+	"not a name 123",
+}
+
+// parserService imitates the API of gnparser web service. Like the real
+// service, it returns results in random order, and skips names listed in
+// skip.
+func parserService(t *testing.T, skip ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var names []string
+			err := jsoniter.NewDecoder(r.Body).Decode(&names)
+			if err != nil {
+				t.Error(err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			gnp := gnparser.NewGNparser()
+			res := make([]string, 0, len(names))
+			for _, name := range names {
+				if inSlice(name, skip) {
+					continue
+				}
+				gnp.Parse(name)
+				bs, err := gnp.ToJSON()
+				if err != nil {
+					t.Error(err)
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				res = append(res, string(bs))
+			}
+			rand.Shuffle(len(res), func(i, j int) {
+				res[i], res[j] = res[j], res[i]
+			})
+			fmt.Fprint(w, "[\n"+strings.Join(res, ",\n")+"]\n")
+		}))
+}
+
+func inSlice(s string, ss []string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRemoteParser(t *testing.T) {
+	ts := parserService(t)
+	defer ts.Close()
+
+	local, err := NewParser("").ParseBatch(testNames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := NewParser(ts.URL).ParseBatch(testNames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remote) != len(testNames) {
+		t.Fatalf("got %d results, want %d", len(remote), len(testNames))
+	}
+	for i, name := range testNames {
+		l := parseName(local[i], name, "1")
+		r := parseName(remote[i], name, "1")
+		if l.ID != r.ID || l.Canonical != r.Canonical ||
+			l.CanonicalWithRank != r.CanonicalWithRank ||
+			l.Surrogate != r.Surrogate || len(l.Positions) != len(r.Positions) ||
+			l.Authorship != r.Authorship || l.Year != r.Year || l.Rank != r.Rank ||
+			l.Quality != r.Quality || l.NameType != r.NameType {
+			t.Errorf("%s: local %+v, remote %+v", name, l, r)
+		}
+		if local[i].NameType != remote[i].NameType {
+			t.Errorf("%s: local type %s, remote type %s", name,
+				local[i].NameType, remote[i].NameType)
+		}
+	}
+}
+
+func TestRemoteParserError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}))
+	defer ts.Close()
+
+	_, err := NewRemoteParser(ts.URL).ParseBatch(testNames)
+	if err == nil {
+		t.Error("expected an error from unavailable service")
+	}
+}
+
+func TestRemoteParserMissingName(t *testing.T) {
+	ts := parserService(t, "Pomatomus")
+	defer ts.Close()
+
+	_, err := NewRemoteParser(ts.URL).ParseBatch(testNames)
+	if err == nil || !strings.Contains(err.Error(), "Pomatomus") {
+		t.Errorf("expected an error about a missing name, got %v", err)
+	}
+}
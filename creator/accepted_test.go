@@ -0,0 +1,221 @@
+package creator
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	badger "github.com/dgraph-io/badger"
+	"github.com/dgraph-io/badger/options"
+	"github.com/dimus/gnidump/util"
+)
+
+// testKV opens a small key-value store in a temporary directory.
+func testKV(t *testing.T) *badger.DB {
+	opts := badger.DefaultOptions(t.TempDir()).WithLogger(nil).
+		WithTableLoadingMode(options.LoadToRAM).
+		WithValueLogLoadingMode(options.FileIO).
+		WithValueLogFileSize(1 << 20)
+	kv, err := badger.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { kv.Close() })
+	return kv
+}
+
+// storeNames saves parsed names under their gni IDs, as converter does.
+func storeNames(t *testing.T, kv *badger.DB, names map[string]string) {
+	wb := kv.NewWriteBatch()
+	for id, name := range names {
+		pn := util.ParsedName{ID: "uuid-" + id, IDOriginal: id, Name: name}
+		if err := wb.Set(util.NameKey(pn.ID), pn.Encode()); err != nil {
+			t.Fatal(err)
+		}
+		if err := wb.Set(util.OriginalKey(id), []byte(pn.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// indexRow creates a name_string_indices row of gni.
+func indexRow(nameStringID string, taxonID string, acceptedID string,
+	pathIDs string) []string {
+	return []string{"1", nameStringID, "", taxonID, "", "", "", "species",
+		acceptedID, "", pathIDs, ""}
+}
+
+// readReport returns rows of a report file without its header.
+func readReport(t *testing.T, path string) [][]string {
+	var res [][]string
+	err := readCSVRows(path, func(row []string) { res = append(res, row) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestAssignAccepted(t *testing.T) {
+	kv := testKV(t)
+	storeNames(t, kv, map[string]string{"1": "Aus bus", "2": "Aus cus",
+		"3": "Aus dus", "4": "Bus bus", "5": "Bus cus", "6": "Cus cus"})
+	rows := [][]string{
+		indexRow("1", "t1", "", ""),
+		indexRow("2", "t2", "t3", ""),
+		indexRow("3", "t3", "t1", ""),
+		indexRow("4", "t4", "t5", ""),
+		indexRow("5", "t5", "t4", ""),
+		indexRow("6", "t6", "t99", ""),
+	}
+	// a chain that is longer than maxChainDepth
+	for i := 0; i <= maxChainDepth+1; i++ {
+		rows = append(rows, indexRow("1", "s"+strconv.Itoa(i),
+			"s"+strconv.Itoa(i+1), ""))
+	}
+	storeIndexData(rows, kv, nil)
+
+	path := filepath.Join(t.TempDir(), "chains.csv")
+	chains := util.NewReportFile(path, []string{"data_source_id", "taxon_id",
+		"problem", "chain"})
+
+	tests := []struct {
+		msg, taxonID, acceptedID string
+		resID, resUUID, resName  string
+		depth                    int
+	}{
+		{"accepted", "t1", "t1", "", "", "", 0},
+		{"one step", "t3", "t1", "t1", "uuid-1", "Aus bus", 1},
+		{"two steps", "t2", "t3", "t1", "uuid-1", "Aus bus", 2},
+		{"cycle", "t4", "t5", "", "", "", 0},
+		{"dangling", "t6", "t99", "", "", "", 0},
+		{"too long", "s0", "s1", "s100", "uuid-1", "Aus bus", maxChainDepth},
+	}
+	for _, v := range tests {
+		id, uuid, name, depth := assignAccepted(v.taxonID, v.acceptedID, "1",
+			chains, kv)
+		if id != v.resID || uuid != v.resUUID || name != v.resName ||
+			depth != v.depth {
+			t.Errorf("%s: got %s %s %s %d, want %s %s %s %d", v.msg, id, uuid,
+				name, depth, v.resID, v.resUUID, v.resName, v.depth)
+		}
+	}
+	chains.Close()
+
+	res := readReport(t, path)
+	if len(res) != 3 {
+		t.Fatalf("got %d reported chains, want 3: %v", len(res), res)
+	}
+	want := [][]string{
+		{"1", "t4", "cycle", "t4|t5|t4"},
+		{"1", "t6", "dangling_id", "t6|t99"},
+	}
+	for i, w := range want {
+		if strings.Join(res[i], ",") != strings.Join(w, ",") {
+			t.Errorf("got %v, want %v", res[i], w)
+		}
+	}
+	if res[2][2] != "too_long" ||
+		len(strings.Split(res[2][3], "|")) != maxChainDepth+1 {
+		t.Errorf("wrong too_long report %v", res[2][:3])
+	}
+}
+
+func TestAcceptedChainDepth(t *testing.T) {
+	kv := testKV(t)
+	storeNames(t, kv, map[string]string{"1": "Aus bus", "2": "Aus cus",
+		"3": "Aus dus"})
+	rows := [][]string{
+		indexRow("1", "t1", "", ""),
+		indexRow("2", "t2", "t3", ""),
+		indexRow("3", "t3", "t1", ""),
+	}
+	storeIndexData(rows, kv, nil)
+
+	dir := t.TempDir()
+	reports := &indexReports{
+		chains: util.NewReportFile(filepath.Join(dir, "chains.csv"), nil),
+		paths: &pathReport{mode: PathsReport,
+			report: util.NewReportFile(filepath.Join(dir, "paths.csv"), nil),
+			counts: make(map[string]map[string]int)},
+	}
+	defer reports.chains.Close()
+	defer reports.paths.report.Close()
+
+	ioJobs := make(chan ioJob, len(rows))
+	canonicalJobs := make(chan canJob, len(rows))
+	for _, row := range rows {
+		indexRowToIO(row, ioJobs, canonicalJobs, reports, kv)
+	}
+	close(ioJobs)
+
+	columns := tables["index"].Columns
+	depths := make(map[string]string)
+	for job := range ioJobs {
+		res := make(map[string]string)
+		for i, c := range columns {
+			res[c] = job.Row[i]
+		}
+		depths[res["taxon_id"]] = res["accepted_chain_depth"] + "|" +
+			res["accepted_taxon_id"] + "|" + res["accepted_name"]
+	}
+	want := map[string]string{"t1": "0||", "t2": "2|t1|Aus bus",
+		"t3": "1|t1|Aus bus"}
+	for k, v := range want {
+		if depths[k] != v {
+			t.Errorf("%s: got %s, want %s", k, depths[k], v)
+		}
+	}
+}
+
+// Synonyms without accepted_taxon_id keep their accepted names, even if
+// their classification paths are dropped.
+func TestAcceptedDroppedPath(t *testing.T) {
+	kv := testKV(t)
+	storeNames(t, kv, map[string]string{"1": "Aus bus", "2": "Aus cus"})
+	rows := [][]string{
+		indexRow("1", "t1", "", "t0|t1"),
+		indexRow("2", "t2", "", "t0|t1"),
+	}
+	for _, row := range rows {
+		row[9] = "Aus|Aus bus"
+		row[11] = "genus"
+	}
+	storeIndexData(rows, kv, nil)
+
+	dir := t.TempDir()
+	reports := &indexReports{
+		chains: util.NewReportFile(filepath.Join(dir, "chains.csv"), nil),
+		paths: &pathReport{mode: PathsDrop,
+			report: util.NewReportFile(filepath.Join(dir, "paths.csv"), nil),
+			counts: make(map[string]map[string]int)},
+	}
+	defer reports.chains.Close()
+	defer reports.paths.report.Close()
+
+	ioJobs := make(chan ioJob, len(rows))
+	canonicalJobs := make(chan canJob, len(rows))
+	for _, row := range rows {
+		indexRowToIO(row, ioJobs, canonicalJobs, reports, kv)
+	}
+	close(ioJobs)
+
+	res := make(map[string][]string)
+	for job := range ioJobs {
+		res[job.Row[3]] = job.Row
+	}
+	syn := res["t2"]
+	if syn[8] != "t1" || syn[12] != "uuid-1" || syn[13] != "Aus bus" ||
+		syn[14] != "1" {
+		t.Errorf("wrong accepted name of synonym: %v", syn)
+	}
+	if syn[9] != "" || syn[10] != "" || syn[11] != "" {
+		t.Errorf("path of synonym is not dropped: %v", syn)
+	}
+	if acc := res["t1"]; acc[8] != "" || acc[14] != "0" {
+		t.Errorf("accepted name has accepted taxon: %v", acc)
+	}
+}
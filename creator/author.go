@@ -0,0 +1,64 @@
+package creator
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"strings"
+	"unicode"
+
+	"github.com/dimus/gnidump/util"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// authorAbbreviationsCSV contains standard abbreviations of names of
+// prolific botanical and zoological authors.
+//
+//go:embed author_abbreviations.csv
+var authorAbbreviationsCSV string
+
+// authorAbbreviations maps abbreviations of authors to their names.
+var authorAbbreviations = abbreviations()
+
+func abbreviations() map[string]string {
+	rows, err := csv.NewReader(strings.NewReader(authorAbbreviationsCSV)).
+		ReadAll()
+	util.Check(err)
+	res := make(map[string]string)
+	for _, row := range rows[1:] {
+		res[row[0]] = row[1]
+	}
+	return res
+}
+
+// letters that are not decomposed into a letter and a diacritical mark.
+var foldReplacer = strings.NewReplacer("ß", "SS", "Æ", "AE", "Œ", "OE",
+	"Ø", "O", "Ł", "L", "Đ", "D", "Þ", "TH")
+
+// foldDiacritics converts a word to upper case and removes diacritical
+// marks from its letters.
+func foldDiacritics(word string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)),
+		norm.NFC)
+	res, _, err := transform.String(t, strings.ToUpper(word))
+	if err != nil {
+		return strings.ToUpper(word)
+	}
+	return foldReplacer.Replace(res)
+}
+
+// normalizeAuthorWord folds diacritics of an author word and expands
+// abbreviated names of well-known authors. Initials, that are followed by
+// other words of the same author, are not expanded, so "L." of "L. Bolus"
+// stays as it is.
+func normalizeAuthorWord(word string, initial bool) string {
+	res := foldDiacritics(strings.TrimSpace(word))
+	if initial {
+		return res
+	}
+	if author, ok := authorAbbreviations[res]; ok {
+		return author
+	}
+	return res
+}
@@ -0,0 +1,27 @@
+package creator
+
+import "testing"
+
+func TestNormalizeAuthorWord(t *testing.T) {
+	tests := []struct {
+		word    string
+		initial bool
+		res     string
+	}{
+		{"Linné", false, "LINNAEUS"},
+		{"LINNE", false, "LINNAEUS"},
+		{"L.", false, "LINNAEUS"},
+		{"Linn.", false, "LINNAEUS"},
+		{"L.", true, "L."},
+		{"Müller", false, "MULLER"},
+		{"Forsskål", false, "FORSSKAL"},
+		{"Strauß", false, "STRAUSS"},
+		{"Łukasz", false, "LUKASZ"},
+		{"Bolus", false, "BOLUS"},
+	}
+	for _, v := range tests {
+		if res := normalizeAuthorWord(v.word, v.initial); res != v.res {
+			t.Errorf("%s: got %s, want %s", v.word, res, v.res)
+		}
+	}
+}
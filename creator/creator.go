@@ -1,16 +1,18 @@
 // Pakcage `gnidump/creator` reads CSV files from gni project and writes
 // modified information into CSV files compatible with gnindex PostgreSQL
-// database.
+// database, or loads it into the database directly.
 package creator
 
 import (
 	"bufio"
-	"bytes"
+	"database/sql"
 	"encoding/csv"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,8 +20,10 @@ import (
 
 	badger "github.com/dgraph-io/badger"
 	"github.com/dimus/gnidump/converter"
+	"github.com/dimus/gnidump/loader"
 	"github.com/dimus/gnidump/util"
 	"github.com/gnames/uuid5"
+	"gitlab.com/gogna/gnparser/pb"
 )
 
 type ioJob struct {
@@ -28,12 +32,143 @@ type ioJob struct {
 }
 
 type canJob struct {
-	Canonical    string
-	DataSourceID int
+	Canonical     string
+	CanonicalStem string
+	DataSourceID  int
 }
 
-// Tables creates CSV files for importing them to gnindex format.
-func Tables() {
+// rowWriter receives rows of a gnindex table.
+type rowWriter interface {
+	Write(row []string) error
+}
+
+// tables describes gnindex tables by the names of their writers.
+var tables = map[string]loader.Table{
+	"name_strings": {Name: "name_strings",
+		Columns: []string{"id", "name", "canonical_uuid", "canonical",
+			"surrogate", "canonical_ranked", "authorship", "year", "rank",
+			"quality", "hybrid", "virus", "canonical_stem", "name_type"},
+		NotNull: []string{"name"}},
+	"author_word": {Name: "name_strings__author_words",
+		Columns: []string{"author_word", "name_uuid", "author_word_raw"}},
+	"genus":      wordTable("name_strings__genus", "genus"),
+	"species":    wordTable("name_strings__species", "species"),
+	"subspecies": wordTable("name_strings__subspecies", "subspecies"),
+	"uninomial":  wordTable("name_strings__uninomial", "uninomial"),
+	"year": {Name: "name_strings__year",
+		Columns: []string{"year", "name_uuid", "approximate"}},
+	"infragenus":  wordTable("name_strings__infragenus", "infragenus"),
+	"rank":        wordTable("name_strings__rank", "rank"),
+	"cultivar":    wordTable("name_strings__cultivar", "cultivar"),
+	"hybrid_char": wordTable("name_strings__hybrid_char", "hybrid_char"),
+	"index": {Name: "name_string_indices",
+		Columns: []string{"data_source_id", "name_string_id", "url", "taxon_id",
+			"global_id", "local_id", "nomenclatural_code_id", "rank",
+			"accepted_taxon_id", "classification_path", "classification_path_ids",
+			"classification_path_ranks", "accepted_name_uuid", "accepted_name",
+			"accepted_chain_depth"}},
+	"vernacular": {Name: "vernacular_strings",
+		Columns: []string{"id", "name"},
+		NotNull: []string{"name"}},
+	"vernacular_index": {Name: "vernacular_string_indices",
+		Columns: []string{"data_source_id", "taxon_id", "vernacular_string_id",
+			"language", "locality", "country_code", "language_orig"}},
+}
+
+// TableNames returns names of gnindex tables created from gni data, except
+// data_sources, which is copied from gni as it is.
+func TableNames() []string {
+	res := make([]string, 0, len(tables))
+	for _, t := range tables {
+		res = append(res, t.Name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func wordTable(name string, word string) loader.Table {
+	return loader.Table{Name: name, Columns: []string{word, "name_uuid"}}
+}
+
+// Tables creates CSV files for importing them to gnindex format. If
+// deterministic is true, rows of every file are sorted, so the same gni data
+// always gives the same files. Inconsistent classification paths are
+// reported, and removed or repaired according to pathMode.
+func Tables(deterministic bool, pathMode PathMode) {
+	csvWriters, files := initTables()
+	defer closeWriters(csvWriters, files)
+
+	writers := make(map[string]rowWriter)
+	sorted := make(map[string]*sortedWriter)
+	for k, v := range csvWriters {
+		if deterministic {
+			sorted[k] = newSortedWriter(v)
+			writers[k] = sorted[k]
+		} else {
+			writers[k] = v
+		}
+	}
+	export(writers, pathMode)
+
+	for k, v := range sorted {
+		log.Println("Sorting", tables[k].Name)
+		v.flush()
+	}
+}
+
+// Load sends gnindex data directly to gnindex PostgreSQL database, without
+// creating CSV files. Columns missing in older gnindex databases are added
+// first by alterTables script. Every table is truncated and loaded in its
+// own transaction, all tables are loaded in parallel.
+func Load(pathMode PathMode, alterTables string) {
+	db := loader.DB()
+	defer db.Close()
+
+	err := loader.AlterTables(db, alterTables)
+	util.Check(err)
+
+	loaders := make(map[string]*loader.Loader)
+	writers := make(map[string]rowWriter)
+	for k, t := range tables {
+		l, err := loader.NewLoader(db, t)
+		util.Check(err)
+		loaders[k] = l
+		writers[k] = l
+	}
+	loadDataSources(db)
+	export(writers, pathMode)
+
+	for _, l := range loaders {
+		err := l.Close()
+		util.Check(err)
+	}
+}
+
+// loadDataSources copies data_sources table from gni dump to gnindex.
+func loadDataSources(db *sql.DB) {
+	f := converter.GniFile("data_sources")
+	defer f.Close()
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	util.Check(err)
+
+	l, err := loader.NewLoader(db,
+		loader.Table{Name: "data_sources", Columns: header})
+	util.Check(err)
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		util.Check(err)
+		err = l.Write(row)
+		util.Check(err)
+	}
+	err = l.Close()
+	util.Check(err)
+}
+
+func export(writers map[string]rowWriter, pathMode PathMode) {
 	ioJobs := make(chan ioJob)
 	canonicalJobs := make(chan canJob)
 
@@ -42,9 +177,6 @@ func Tables() {
 	var ioWG sync.WaitGroup
 	var canonicalWG sync.WaitGroup
 
-	writers, files := initTables()
-	defer closeWriters(writers, files)
-
 	kv := util.InitBadger()
 	defer func() {
 		err := kv.Close()
@@ -52,19 +184,31 @@ func Tables() {
 	}()
 
 	ioWG.Add(1)
-	go writeToCSVs(writers, ioJobs, &ioWG)
+	go writeRows(writers, ioJobs, &ioWG)
 
 	canonicalWG.Add(1)
-	go collectCanonical(canonicalJobs, &canonicalWG)
+	go collectCanonical(util.GnindexDir, canonicalJobs, &canonicalWG)
 
-	exportNameStrings(kv, ioJobs, &nameStringsWG)
+	q := newQuarantine()
+	// nomenclatural codes of names from index data are needed to check years
 	prepareIndexData(kv)
+	years := newYearReport()
+	exportNameStrings(kv, ioJobs, q, years, &nameStringsWG)
 	nameStringsWG.Wait()
+	years.close()
 
-	exportNameStringIndices(kv, ioJobs, canonicalJobs, &indexWG)
+	reports := &indexReports{
+		chains: util.NewReport("accepted_name_chains",
+			[]string{"data_source_id", "taxon_id", "problem", "chain"}, false),
+		paths:      newPathReport(pathMode),
+		quarantine: q,
+	}
+	exportNameStringIndices(kv, ioJobs, canonicalJobs, reports, &indexWG)
 	indexWG.Wait()
+	reports.close()
 
-	exportVernaculars(ioJobs)
+	exportVernaculars(ioJobs, kv, q)
+	q.close()
 
 	close(ioJobs)
 	close(canonicalJobs)
@@ -72,22 +216,82 @@ func Tables() {
 	canonicalWG.Wait()
 }
 
-func collectCanonical(canonicalJobs <-chan canJob,
+// Canonicals writes sorted files of unique canonical forms to a directory,
+// using names from gni name_string_indices.
+func Canonicals(dir string) {
+	canonicalJobs := make(chan canJob)
+	indexJobs := make(chan [][]string)
+	var canonicalWG sync.WaitGroup
+	var indexWG sync.WaitGroup
+
+	kv := util.InitBadger()
+	defer func() {
+		err := kv.Close()
+		util.Check(err)
+	}()
+
+	canonicalWG.Add(1)
+	go collectCanonical(dir, canonicalJobs, &canonicalWG)
+
+	for i := 1; i <= util.WorkersNum(); i++ {
+		indexWG.Add(1)
+		go canonicalWorker(indexJobs, canonicalJobs, &indexWG, kv)
+	}
+	collectIndexJobs(indexJobs)
+	indexWG.Wait()
+
+	close(canonicalJobs)
+	canonicalWG.Wait()
+}
+
+func canonicalWorker(indexJobs <-chan [][]string, canonicalJobs chan<- canJob,
+	indexWG *sync.WaitGroup, kv *badger.DB) {
+	defer indexWG.Done()
+	for job := range indexJobs {
+		for _, row := range job {
+			parsedName, err := parsedNameFromID(row[1], kv)
+			if err != nil {
+				continue
+			}
+			dsID, err := strconv.Atoi(row[0])
+			util.Check(err)
+			canonicalJobs <- canJob{parsedName.Canonical, parsedName.CanonicalStem,
+				dsID}
+		}
+	}
+}
+
+// collectCanonical sorts canonical forms, canonical forms with data sources,
+// and stems of canonical forms, using external sort, so all of them do not
+// have to fit into memory.
+func collectCanonical(dir string, canonicalJobs <-chan canJob,
 	canonicalWG *sync.WaitGroup) {
 	defer canonicalWG.Done()
 
-	canonicals := make(map[string]map[int]struct{})
+	canonicals := newSorter(dir, true)
+	canDataSources := newSorter(dir, true)
+	stems := newSorter(dir, true)
+	defer func() {
+		for _, s := range []*sorter{canonicals, canDataSources, stems} {
+			s.close()
+		}
+	}()
+
 	for c := range canonicalJobs {
-		if _, ok := canonicals[c.Canonical]; ok {
-			canonicals[c.Canonical][c.DataSourceID] = struct{}{}
-		} else {
-			ds := make(map[int]struct{})
-			ds[c.DataSourceID] = struct{}{}
-			canonicals[c.Canonical] = ds
+		if c.Canonical != "" {
+			canonicals.add([]string{c.Canonical})
+			canDataSources.add([]string{c.Canonical, strconv.Itoa(c.DataSourceID)})
+		}
+		if c.CanonicalStem != "" {
+			stems.add([]string{c.CanonicalStem})
 		}
 	}
 
-	saveCanonicals(canonicals)
+	log.Println("Writing canonicals to files")
+	saveCanonicals(canonicals, filepath.Join(dir, "canonical_names_"+dateStr()))
+	saveCanonicals(canDataSources,
+		filepath.Join(dir, "canonical_names_with_datasource_"+dateStr()))
+	saveCanonicals(stems, filepath.Join(dir, "canonical_names_stem_"+dateStr()))
 }
 
 func dateStr() string {
@@ -95,94 +299,110 @@ func dateStr() string {
 	return fmt.Sprintf("%d%02d%02d", t.Year(), t.Month(), t.Day())
 }
 
-func saveCanonicals(canonicals map[string]map[int]struct{}) {
-	log.Println("Writing canonicals to files")
-	f1 := txtFile("canonical_names_" + dateStr())
-	f2 := txtFile("canonical_names_with_datasource_" + dateStr())
-	canonicalWriter := bufio.NewWriter(f1)
-	canDataSourceWriter := bufio.NewWriter(f2)
-
-	for can, ids := range canonicals {
-		if can != "" {
-			_, err := canonicalWriter.WriteString(can + "\n")
-			util.Check(err)
-			for k := range ids {
-				idString := strconv.Itoa(k)
-				_, err = canDataSourceWriter.WriteString(can + "\t" + idString + "\n")
-				util.Check(err)
-			}
-		}
-	}
-
-	err := canonicalWriter.Flush()
+// saveCanonicals writes sorted rows to a text file, with fields separated by
+// tabs.
+func saveCanonicals(s *sorter, path string) {
+	f := txtFile(path)
+	w := bufio.NewWriter(f)
+	s.each(func(row []string) {
+		_, err := w.WriteString(strings.Join(row, "\t") + "\n")
+		util.Check(err)
+	})
+	err := w.Flush()
 	util.Check(err)
-	err = canDataSourceWriter.Flush()
+	err = f.Sync()
 	util.Check(err)
-	err = f1.Sync()
+	err = f.Close()
 	util.Check(err)
-	err = f2.Sync()
+}
+
+// exportVernaculars reads vernacular strings and their indices from gni
+// files row by row. gni IDs of vernacular strings are mapped to UUIDs in the
+// key-value store. Index rows that refer to unknown vernacular strings are
+// moved to quarantine.
+func exportVernaculars(ioJobs chan<- ioJob, kv *badger.DB, q *quarantine) {
+	log.Println("Export to vernacular_strings")
+	// IDs of vernacular strings of a previous run might be gone from gni
+	err := kv.DropPrefix([]byte(util.VernacularPrefix))
 	util.Check(err)
-	err = f1.Close()
+	wb := kv.NewWriteBatch()
+	err = readCSVRows(util.GniDir+"vernacular_strings.csv", func(row []string) {
+		vernacularUUID := uuid5.UUID5(row[1]).String()
+		err := wb.Set(util.VernacularKey(row[0]), []byte(vernacularUUID))
+		util.Check(err)
+		ioJobs <- ioJob{"vernacular", []string{vernacularUUID, row[1]}}
+	})
 	util.Check(err)
-	err = f2.Close()
+	err = wb.Flush()
 	util.Check(err)
-}
 
-func exportVernaculars(ioJobs chan<- ioJob) {
-	vernacularMap := make(map[string]string)
-	f := converter.GniFile("vernacular_strings")
-	r := csv.NewReader(f)
-
-	fmt.Println("Export to vernacular_strings")
-	records, err := r.ReadAll()
+	log.Println("Export to vernacular_string_indices")
+	txn := kv.NewTransaction(false)
+	defer txn.Discard()
+	var dataSourceID, taxonID, vernacularStringID, languageOrig, locality,
+		countryCode string
+	unmapped := make(map[string]int)
+	err = readCSVRows(util.GniDir+"vernacular_string_indices.csv",
+		func(row []string) {
+			unpackSlice(row, &dataSourceID, &taxonID, &vernacularStringID,
+				&languageOrig, &locality, &countryCode)
+			vernacularUUID, ok := vernacularUUID(vernacularStringID, txn)
+			if !ok {
+				q.add("vernacular_string_indices", reasonVernacularNotFound, row)
+				return
+			}
+			language, ok := normalizeLanguage(languageOrig)
+			if !ok && strings.TrimSpace(languageOrig) != "" {
+				unmapped[languageOrig]++
+			}
+			csvRow := []string{dataSourceID, taxonID, vernacularUUID, language,
+				locality, countryCode, languageOrig}
+			ioJobs <- ioJob{"vernacular_index", csvRow}
+		})
+	util.Check(err)
 
-	for _, v := range records[1:] {
-		vernacularID := v[0]
-		vernacularName := v[1]
-		util.Check(err)
-		vernacularUUID := uuid5.UUID5(vernacularName).String()
-		vernacularMap[vernacularID] = vernacularUUID
-		ioJobs <- ioJob{"vernacular", []string{vernacularUUID, vernacularName}}
+	saveUnmappedLanguages(unmapped)
+	if len(unmapped) > 0 {
+		log.Printf("%d languages of vernacular names are not recognized, "+
+			"see unmapped_languages.csv report\n", len(unmapped))
 	}
+}
 
-	fmt.Println("Export to vernacular_string_indices")
-	f2 := converter.GniFile("vernacular_string_indices")
-	r2 := csv.NewReader(f2)
-
-	records2, err := r2.ReadAll()
-	util.Check(err)
-	var dataSourceID, taxonID, vernacularStringID, language, locality,
-		countryCode string
-	for _, v := range records2[1:] {
-		unpackSlice(v, &dataSourceID, &taxonID, &vernacularStringID, &language,
-			&locality, &countryCode)
-		vernacularStringID = vernacularMap[vernacularStringID]
-		csvRow := []string{dataSourceID, taxonID, vernacularStringID, language,
-			locality, countryCode}
-		ioJobs <- ioJob{"vernacular_index", csvRow}
+// vernacularUUID returns UUID of a vernacular string by its gni ID.
+func vernacularUUID(id string, txn *badger.Txn) (string, bool) {
+	item, err := txn.Get(util.VernacularKey(id))
+	if err == badger.ErrKeyNotFound {
+		return "", false
 	}
+	util.Check(err)
+	var res []byte
+	res, err = item.ValueCopy(res)
+	util.Check(err)
+	return string(res), true
 }
 
 func exportNameStringIndices(kv *badger.DB, ioJobs chan<- ioJob,
-	canonicalJobs chan<- canJob, indexWG *sync.WaitGroup) {
+	canonicalJobs chan<- canJob, reports *indexReports,
+	indexWG *sync.WaitGroup) {
 	indexJobs := make(chan [][]string)
 
 	for i := 1; i <= util.WorkersNum(); i++ {
 		indexWG.Add(1)
-		go indexWorker(i, indexJobs, ioJobs, canonicalJobs, indexWG, kv)
+		go indexWorker(i, indexJobs, ioJobs, canonicalJobs, reports, indexWG, kv)
 	}
 
 	go collectIndexJobs(indexJobs)
 }
 
 func indexWorker(workerID int, indexJobs <-chan [][]string, ioJobs chan<- ioJob,
-	canonicalJobs chan<- canJob, indexWG *sync.WaitGroup, kv *badger.DB) {
+	canonicalJobs chan<- canJob, reports *indexReports, indexWG *sync.WaitGroup,
+	kv *badger.DB) {
 	defer indexWG.Done()
 	for {
 		job, more := <-indexJobs
 		if more {
 			log.Printf("NSIndex export %d: %s", workerID, job[0][0:2])
-			exportIndexRows(job, ioJobs, canonicalJobs, kv)
+			exportIndexRows(job, ioJobs, canonicalJobs, reports, kv)
 		} else {
 			return
 		}
@@ -190,14 +410,14 @@ func indexWorker(workerID int, indexJobs <-chan [][]string, ioJobs chan<- ioJob,
 }
 
 func exportIndexRows(job [][]string, ioJobs chan<- ioJob,
-	canonicalJobs chan<- canJob, kv *badger.DB) {
+	canonicalJobs chan<- canJob, reports *indexReports, kv *badger.DB) {
 	for _, row := range job {
-		indexRowToIO(row, ioJobs, canonicalJobs, kv)
+		indexRowToIO(row, ioJobs, canonicalJobs, reports, kv)
 	}
 }
 
 func indexRowToIO(row []string, ioJobs chan<- ioJob,
-	canonicalJobs chan<- canJob, kv *badger.DB) {
+	canonicalJobs chan<- canJob, reports *indexReports, kv *badger.DB) {
 	var dataSourceID, nameStringID, url, taxonID, globalID, localID,
 		nomenclaturalCodeID, rank, acceptedTaxonID, classificationPath,
 		classificationPathIDs, classificationPathRanks, acceptedNameUUID,
@@ -206,6 +426,9 @@ func indexRowToIO(row []string, ioJobs chan<- ioJob,
 	unpackSlice(row, &dataSourceID, &nameStringID, &url, &taxonID, &globalID,
 		&localID, &nomenclaturalCodeID, &rank, &acceptedTaxonID,
 		&classificationPath, &classificationPathIDs, &classificationPathRanks)
+	path := reports.paths.check(row)
+	unpackSlice(path, &classificationPath, &classificationPathIDs,
+		&classificationPathRanks)
 
 	parsedName, err := parsedNameFromID(nameStringID, kv)
 	if err == nil {
@@ -213,41 +436,114 @@ func indexRowToIO(row []string, ioJobs chan<- ioJob,
 
 		dsID, err := strconv.Atoi(dataSourceID)
 		util.Check(err)
-		canonicalJobs <- canJob{parsedName.Canonical, dsID}
+		canonicalJobs <- canJob{parsedName.Canonical, parsedName.CanonicalStem,
+			dsID}
 
-		acceptedTaxonID, acceptedNameUUID, acceptedName = assignAccepted(taxonID,
-			acceptedTaxonID, classificationPathIDs, dataSourceID, kv)
+		var depth int
+		acceptedTaxonID, acceptedNameUUID, acceptedName, depth = assignAccepted(
+			taxonID, acceptedID(row), dataSourceID, reports.chains, kv)
 
 		csvRow := []string{dataSourceID, nameStringUUID, url, taxonID, globalID,
 			localID, nomenclaturalCodeID, rank, acceptedTaxonID, classificationPath,
 			classificationPathIDs, classificationPathRanks, acceptedNameUUID,
-			acceptedName}
+			acceptedName, strconv.Itoa(depth)}
 		ioJobs <- ioJob{"index", csvRow}
 	} else {
-		log.Println("Broken record:", dataSourceID, nameStringID, taxonID)
+		reports.quarantine.add("name_string_indices", reasonNameNotFound, row)
 	}
 }
 
+// maxChainDepth limits the number of steps from a synonym to its accepted
+// name.
+const maxChainDepth = 100
+
+// assignAccepted finds the accepted name of a taxon inside of its data
+// source, starting from acceptedTaxonID found by acceptedID. If the accepted
+// taxon is a synonym itself, its accepted taxon is used, until a taxon is
+// found that is not a synonym. It returns ID, name UUID and name of the
+// accepted taxon, and the number of steps to it. Cycles, too long chains and
+// IDs that do not exist in the data source are reported. A chain that is too
+// long gives the last taxon found in it. A cycle has no accepted taxon, so
+// for cycles, like for missing IDs, the accepted fields are left empty.
 func assignAccepted(taxonID string, acceptedTaxonID string,
-	classificationPathIDs string, dataSourceID string,
-	kv *badger.DB) (string, string, string) {
-	var acceptedName, acceptedNameUUID string
+	dataSourceID string, chains *util.Report, kv *badger.DB) (string, string,
+	string, int) {
+	if taxonID == acceptedTaxonID {
+		return "", "", "", 0
+	}
+
+	txn := kv.NewTransaction(false)
+	defer txn.Discard()
 
-	if acceptedTaxonID == "" {
-		acceptedTaxonID = lastPathID(classificationPathIDs, taxonID)
+	chain := []string{taxonID}
+	var resolvedID, nameStringID string
+	depth := 0
+	current := acceptedTaxonID
+	for {
+		if inChain(chain, current) {
+			reportChain(chains, dataSourceID, "cycle", append(chain, current))
+			return "", "", "", 0
+		}
+		nsID, next, ok := indexRecord(dataSourceID, current, txn)
+		if !ok {
+			reportChain(chains, dataSourceID, "dangling_id",
+				append(chain, current))
+			break
+		}
+		chain = append(chain, current)
+		resolvedID, nameStringID = current, nsID
+		depth++
+		if next == current {
+			break
+		}
+		if depth >= maxChainDepth {
+			reportChain(chains, dataSourceID, "too_long", chain)
+			break
+		}
+		current = next
 	}
 
-	if taxonID == acceptedTaxonID {
-		acceptedTaxonID, acceptedNameUUID, acceptedName = "", "", ""
-	} else {
-		acceptedNameUUID, acceptedName = findAcceptedName(dataSourceID,
-			acceptedTaxonID, kv)
+	if resolvedID == "" {
+		return "", "", "", 0
+	}
+	parsedName, err := parsedNameTxn(nameStringID, txn)
+	if err != nil {
+		log.Printf("Accepted name %s of %s|%s is not found: %s\n", nameStringID,
+			dataSourceID, taxonID, err)
+		return "", "", "", 0
+	}
+	return resolvedID, parsedName.ID, parsedName.Name, depth
+}
 
-		if acceptedNameUUID == "" {
-			acceptedTaxonID = ""
+func inChain(chain []string, taxonID string) bool {
+	for _, v := range chain {
+		if v == taxonID {
+			return true
 		}
 	}
-	return acceptedTaxonID, acceptedNameUUID, acceptedName
+	return false
+}
+
+// indexReports collect problems found in name_string_indices.
+type indexReports struct {
+	chains     *util.Report
+	paths      *pathReport
+	quarantine *quarantine
+}
+
+func (r *indexReports) close() {
+	r.chains.Close()
+	if n := r.chains.Count(); n > 0 {
+		log.Printf("Found %d broken chains of accepted names, "+
+			"see accepted_name_chains.csv report\n", n)
+	}
+	r.paths.close()
+}
+
+func reportChain(chains *util.Report, dataSourceID string, problem string,
+	chain []string) {
+	chains.Write([]string{dataSourceID, chain[0], problem,
+		strings.Join(chain, "|")})
 }
 
 func lastPathID(PathIDs string, taxonID string) string {
@@ -260,28 +556,20 @@ func lastPathID(PathIDs string, taxonID string) string {
 	}
 }
 
-func findAcceptedName(dataSourceID string, taxonID string,
-	kv *badger.DB) (string, string) {
-	txn := kv.NewTransaction(false)
-	defer txn.Commit()
-	var acceptedName, acceptedNameUUID string
-	key := indexKey(dataSourceID, taxonID)
-
-	item, err := txn.Get(key)
+// indexRecord returns gni name-string ID of a taxon and ID of the taxon that
+// is accepted for it. Both are saved by prepareIndexData.
+func indexRecord(dataSourceID string, taxonID string,
+	txn *badger.Txn) (string, string, bool) {
+	item, err := txn.Get(indexKey(dataSourceID, taxonID))
 	if err == badger.ErrKeyNotFound {
-		return "", ""
-	} else if err != nil {
-		log.Printf("+++++++%s+++++++", err)
-		return "", ""
+		return "", "", false
 	}
+	util.Check(err)
 	var res []byte
 	res, err = item.ValueCopy(res)
 	util.Check(err)
-	parsedName, err := parsedNameFromID(string(res), kv)
-	util.Check(err)
-	acceptedName = parsedName.Name
-	acceptedNameUUID = parsedName.ID
-	return acceptedNameUUID, acceptedName
+	fields := strings.SplitN(string(res), "|", 2)
+	return fields[0], fields[1], true
 }
 
 func unpackSlice(row []string, vars ...*string) {
@@ -291,7 +579,7 @@ func unpackSlice(row []string, vars ...*string) {
 }
 
 func collectIndexJobs(indexJobs chan<- [][]string) {
-	log.Println("Export name_string_indices to CSV file")
+	log.Println("Reading name_string_indices from gni CSV file")
 	f := converter.GniFile("name_string_indices")
 	chunkSize := 10000
 	r := csv.NewReader(f)
@@ -318,18 +606,21 @@ func collectIndexJobs(indexJobs chan<- [][]string) {
 		}
 		i++
 	}
-	indexJobs <- rows
+	if i > 0 {
+		indexJobs <- rows[:i]
+	}
 
 	close(indexJobs)
 }
 
-func exportNameStrings(kv *badger.DB, ioJobs chan<- ioJob,
-	nameStringsWG *sync.WaitGroup) {
+func exportNameStrings(kv *badger.DB, ioJobs chan<- ioJob, q *quarantine,
+	years *yearReport, nameStringsWG *sync.WaitGroup) {
 	nameStringsJobs := make(chan [][]string)
 
 	for i := 1; i <= util.WorkersNum(); i++ {
 		nameStringsWG.Add(1)
-		go nameStringsWorker(i, nameStringsJobs, ioJobs, nameStringsWG, kv)
+		go nameStringsWorker(i, nameStringsJobs, ioJobs, q, years, nameStringsWG,
+			kv)
 	}
 
 	go collectNameStringsJobs(nameStringsJobs)
@@ -337,11 +628,16 @@ func exportNameStrings(kv *badger.DB, ioJobs chan<- ioJob,
 
 func prepareIndexData(kv *badger.DB) {
 	log.Println("Getting name_string_indices from CSV file")
+	codes, err := dataSourceCodes(util.EnvVars()["nomenclatural_codes"])
+	util.Check(err)
+	// codes of a previous run might use other defaults of data sources
+	err = kv.DropPrefix([]byte(util.CodePrefix))
+	util.Check(err)
 	f := converter.GniFile("name_string_indices")
 	r := csv.NewReader(f)
 
 	//skip header
-	_, err := r.Read()
+	_, err = r.Read()
 	util.Check(err)
 
 	i := 0
@@ -360,16 +656,13 @@ func prepareIndexData(kv *badger.DB) {
 			if count%100000 == 0 {
 				log.Printf("Saved %d index keys\n", count)
 			}
-			storeIndexData(rows, kv)
+			storeIndexData(rows, kv, codes)
 			i = 0
 			rows[i] = row
 		}
 		i++
 	}
-	// Some of the duplicates are writen second time, but it is a drop in a
-	// bucket. It is OK to send slices by value, as only header will be
-	// copied, the slice itself is send in the header by reference
-	storeIndexData(rows, kv)
+	storeIndexData(rows[:i], kv, codes)
 }
 
 func indexKey(dataSourceID string, taxonID string) []byte {
@@ -377,9 +670,10 @@ func indexKey(dataSourceID string, taxonID string) []byte {
 	return append(key0, []byte(taxonID)...)
 }
 
-func storeIndexData(rows [][]string, kv *badger.DB) {
+func storeIndexData(rows [][]string, kv *badger.DB, codes map[string]string) {
 	var err error
 	entries := badgerizeIndexes(rows)
+	entries = append(entries, badgerizeCodes(rows, kv, codes)...)
 	wb := kv.NewWriteBatch()
 	for _, v := range entries {
 		err = wb.SetEntry(v)
@@ -394,19 +688,52 @@ func storeIndexData(rows [][]string, kv *badger.DB) {
 	util.Check(err)
 }
 
+// indexValue joins gni name-string ID of a taxon with ID of its accepted
+// taxon. For accepted taxa both IDs are the same.
+func indexValue(row []string) []byte {
+	return []byte(row[1] + "|" + acceptedID(row))
+}
+
 func badgerizeIndexes(rows [][]string) []*badger.Entry {
 	batchSize := len(rows)
 	entries := make([]*badger.Entry, batchSize)
 	for i, row := range rows {
 		key := indexKey(row[0], row[3])
-		value := []byte(row[1])
+		value := indexValue(row)
 		entry := badger.Entry{Key: key, Value: value}
 		entries[i] = &entry
 	}
 	return entries
 }
 
-func writeToCSVs(writers map[string]*csv.Writer, ioJobs <-chan ioJob,
+// badgerizeCodes creates entries that keep nomenclatural codes of names. Rows
+// without a code use the default code of their data source.
+func badgerizeCodes(rows [][]string, kv *badger.DB,
+	codes map[string]string) []*badger.Entry {
+	txn := kv.NewTransaction(false)
+	defer txn.Discard()
+
+	var entries []*badger.Entry
+	for _, row := range rows {
+		code := rowCode(row, codes)
+		if code == "" {
+			continue
+		}
+		item, err := txn.Get(util.OriginalKey(row[1]))
+		if err == badger.ErrKeyNotFound {
+			continue
+		}
+		util.Check(err)
+		var uuid []byte
+		uuid, err = item.ValueCopy(uuid)
+		util.Check(err)
+		entries = append(entries,
+			&badger.Entry{Key: util.CodeKey(string(uuid), code), Value: []byte{}})
+	}
+	return entries
+}
+
+func writeRows(writers map[string]rowWriter, ioJobs <-chan ioJob,
 	ioWG *sync.WaitGroup) {
 	defer ioWG.Done()
 	log.Println("Waiting for ioJobs")
@@ -417,28 +744,18 @@ func writeToCSVs(writers map[string]*csv.Writer, ioJobs <-chan ioJob,
 }
 
 func collectNameStringsJobs(nameStringsJobs chan<- [][]string) {
-	gniRecords := converter.ReadCSVNameStrings()
-	totalSize := len(gniRecords)
-	chunkSize := 10000
-
-	for i := 1; i < totalSize; i += chunkSize {
-		end := i + chunkSize
-		if end > totalSize {
-			end = totalSize
-		}
-		nameStringsJobs <- gniRecords[i:end]
-	}
-	close(nameStringsJobs)
+	converter.ReadCSVNameStrings(nameStringsJobs, converter.ChunkSize)
 }
 
 func nameStringsWorker(workerID int, nameStringsJobs <-chan [][]string,
-	ioJobs chan<- ioJob, nameStringsWG *sync.WaitGroup, kv *badger.DB) {
+	ioJobs chan<- ioJob, q *quarantine, years *yearReport,
+	nameStringsWG *sync.WaitGroup, kv *badger.DB) {
 	defer nameStringsWG.Done()
 	for {
 		job, more := <-nameStringsJobs
 		if more {
 			log.Printf("NS export %d: %s", workerID, job[0][1])
-			processNameStringsRows(job, ioJobs, kv)
+			processNameStringsRows(job, ioJobs, q, years, kv)
 		} else {
 			return
 		}
@@ -446,15 +763,25 @@ func nameStringsWorker(workerID int, nameStringsJobs <-chan [][]string,
 }
 
 func processNameStringsRows(job [][]string, ioJobs chan<- ioJob,
-	kv *badger.DB) {
+	q *quarantine, years *yearReport, kv *badger.DB) {
+	txn := kv.NewTransaction(false)
+	defer txn.Discard()
 	for _, row := range job {
-		pn, err := parsedNameFromID(row[0], kv)
+		pn, err := parsedNameTxn(row[0], txn)
 		if err != nil {
-			log.Printf("**********%s: %s**********", row[0], err)
+			q.add("name_strings", reasonNameNotFound, row)
+			continue
+		}
+		if pn.IDOriginal != row[0] {
+			// duplicate of a name-string exported under its first gni ID
+			continue
 		}
-		processWords(&pn, ioJobs)
+		processWords(&pn, ioJobs, years, txn)
 		csvRow := []string{pn.ID, pn.Name, pn.IDCanonical, pn.Canonical,
-			strconv.FormatBool(pn.Surrogate), pn.CanonicalWithRank}
+			strconv.FormatBool(pn.Surrogate), pn.CanonicalWithRank, pn.Authorship,
+			pn.Year, pn.Rank, strconv.Itoa(pn.Quality),
+			strconv.FormatBool(pn.Hybrid), strconv.FormatBool(pn.Virus),
+			pn.CanonicalStem, pn.NameType.String()}
 		ioJobs <- ioJob{"name_strings", csvRow}
 	}
 }
@@ -463,25 +790,40 @@ func parsedNameFromID(nameStringID string,
 	kv *badger.DB) (util.ParsedName, error) {
 	txn := kv.NewTransaction(false)
 	defer txn.Commit()
-	item, err := txn.Get([]byte(nameStringID))
-	if err == badger.ErrKeyNotFound {
+	return parsedNameTxn(nameStringID, txn)
+}
+
+// parsedNameTxn finds UUID of a gni name-string ID in the index of original
+// IDs, and returns ParsedName stored under this UUID.
+func parsedNameTxn(nameStringID string,
+	txn *badger.Txn) (util.ParsedName, error) {
+	item, err := txn.Get(util.OriginalKey(nameStringID))
+	if err != nil {
 		return util.ParsedName{}, err
-	} else if err != nil {
+	}
+	var uuid []byte
+	uuid, err = item.ValueCopy(uuid)
+	util.Check(err)
+
+	item, err = txn.Get(util.NameKey(string(uuid)))
+	if err != nil {
 		return util.ParsedName{}, err
 	}
 	var res []byte
 	res, err = item.ValueCopy(res)
 	util.Check(err)
-	record := bytes.NewBuffer(res)
-	return util.DecodeGob(*record), nil
+	return util.Decode(res)
 }
 
-func processWords(parsedName *util.ParsedName, ioJobs chan<- ioJob) {
+func processWords(parsedName *util.ParsedName, ioJobs chan<- ioJob,
+	years *yearReport, txn *badger.Txn) {
 	pos := parsedName.Positions
 	id := parsedName.ID
 	name := parsedName.Name
+	var codes []string
+	codesFound := false
 
-	for _, v := range pos {
+	for i, v := range pos {
 		wordUpper := strings.ToUpper(string([]rune(name)[v.Start:v.End]))
 		word := strings.Trim(wordUpper, " ")
 		switch v.Type {
@@ -493,24 +835,48 @@ func processWords(parsedName *util.ParsedName, ioJobs chan<- ioJob) {
 			ioJobs <- ioJob{"species", []string{word, id}}
 		case "infraspecificEpithet":
 			ioJobs <- ioJob{"subspecies", []string{word, id}}
+		case "infragenericEpithet":
+			ioJobs <- ioJob{"infragenus", []string{word, id}}
+		case "rank":
+			ioJobs <- ioJob{"rank", []string{word, id}}
+		case "hybridChar":
+			ioJobs <- ioJob{"hybrid_char", []string{word, id}}
+		case "cultivar":
+			// the current version of gnparser does not mark cultivar names yet
+			ioJobs <- ioJob{"cultivar", []string{word, id}}
 		case "authorWord":
-			if strings.TrimSpace(word) != "" {
-				ioJobs <- ioJob{"author_word", []string{word, id}}
+			if word != "" {
+				raw := strings.TrimSpace(string([]rune(name)[v.Start:v.End]))
+				normalized := normalizeAuthorWord(raw, isInitial(pos, i, name))
+				ioJobs <- ioJob{"author_word", []string{normalized, id, raw}}
 			}
-		case "year":
-			yr, err := strconv.Atoi(word)
-			if err != nil {
-				yr = 1
+		case "year", "approximateYear":
+			if !codesFound {
+				codes = nameCodes(id, txn)
+				codesFound = true
 			}
-			now := time.Now()
-			maxYear := now.Year() + 2
-			if (yr >= 1753) && (yr <= maxYear) {
-				ioJobs <- ioJob{"year", []string{word, id}}
+			yr, reason := checkYear(word, codes, time.Now())
+			if reason != "" {
+				years.add(parsedName, word, codes, reason)
+				continue
 			}
+			approximate := strconv.FormatBool(v.Type == "approximateYear" ||
+				strings.Contains(word, "?"))
+			ioJobs <- ioJob{"year", []string{yr, id, approximate}}
 		}
 	}
 }
 
+// isInitial is true if an author word is followed by another word of the
+// same author.
+func isInitial(pos []*pb.Position, i int, name string) bool {
+	if i+1 >= len(pos) || pos[i+1].Type != "authorWord" {
+		return false
+	}
+	gap := string([]rune(name)[pos[i].End:pos[i+1].Start])
+	return strings.TrimSpace(gap) == ""
+}
+
 func closeWriters(writers map[string]*csv.Writer, files map[string]*os.File) {
 	for name, w := range writers {
 		log.Println("Flushing writer", name)
@@ -528,46 +894,16 @@ func closeWriters(writers map[string]*csv.Writer, files map[string]*os.File) {
 
 func initTables() (map[string]*csv.Writer, map[string]*os.File) {
 	util.CleanDir(util.GnindexDir)
-	var files = map[string]*os.File{
-		"name_strings":     pgCsvFile("name_strings"),
-		"author_word":      pgCsvFile("name_strings__author_words"),
-		"genus":            pgCsvFile("name_strings__genus"),
-		"species":          pgCsvFile("name_strings__species"),
-		"subspecies":       pgCsvFile("name_strings__subspecies"),
-		"uninomial":        pgCsvFile("name_strings__uninomial"),
-		"year":             pgCsvFile("name_strings__year"),
-		"index":            pgCsvFile("name_string_indices"),
-		"vernacular":       pgCsvFile("vernacular_strings"),
-		"vernacular_index": pgCsvFile("vernacular_string_indices")}
+	files := make(map[string]*os.File)
+	for k, t := range tables {
+		files[k] = pgCsvFile(t.Name)
+	}
 
 	writers := make(map[string]*csv.Writer)
 	for k, v := range files {
 		writers[k] = csv.NewWriter(v)
-	}
-
-	for k, v := range writers {
-		if k == "name_strings" {
-			err := v.Write([]string{"id", "name",
-				"canonical_uuid", "canonical", "surrogate", "canonical_ranked"})
-			util.Check(err)
-		} else if k == "index" {
-			err := v.Write([]string{"data_source_id", "name_string_id",
-				"url,taxon_id", "global_id", "local_id", "nomenclatural_code_id",
-				"rank", "accepted_taxon_id", "classification_path",
-				"classification_path_ids", "classification_path_ranks",
-				"accepted_name_uuid", "accepted_name"})
-			util.Check(err)
-		} else if k == "vernacular" {
-			err := v.Write([]string{"id", "name"})
-			util.Check(err)
-		} else if k == "vernacular_index" {
-			err := v.Write([]string{"data_source_id", "taxon_id",
-				"vernacular_string_id", "language", "locality", "country_code"})
-			util.Check(err)
-		} else {
-			err := v.Write([]string{k, "name_uuid"})
-			util.Check(err)
-		}
+		err := writers[k].Write(tables[k].Columns)
+		util.Check(err)
 	}
 	return writers, files
 }
@@ -578,8 +914,8 @@ func pgCsvFile(f string) *os.File {
 	return file
 }
 
-func txtFile(f string) *os.File {
-	file, err := os.Create(util.GnindexDir + f + ".txt")
+func txtFile(path string) *os.File {
+	file, err := os.Create(path + ".txt")
 	util.Check(err)
 	return file
 }
@@ -0,0 +1,130 @@
+package creator
+
+import (
+	"strings"
+	"testing"
+
+	badger "github.com/dgraph-io/badger"
+	"github.com/dimus/gnidump/util"
+	"gitlab.com/gogna/gnparser"
+	"gitlab.com/gogna/gnparser/pb"
+)
+
+// wordRows parses a name and returns rows that processWords sends to word
+// tables, joined with commas.
+func wordRows(name string, years *yearReport,
+	txn *badger.Txn) map[string][]string {
+	gnp := gnparser.NewGNparser()
+	p := gnp.ParseToObject(name)
+	pn := util.ParsedName{ID: "uuid-1", Name: name, Positions: p.Positions}
+	ioJobs := make(chan ioJob, 100)
+	processWords(&pn, ioJobs, years, txn)
+	close(ioJobs)
+	res := make(map[string][]string)
+	for job := range ioJobs {
+		res[job.Writer] = append(res[job.Writer], strings.Join(job.Row, ","))
+	}
+	return res
+}
+
+func TestProcessWords(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		rows  []string
+	}{
+		{"Aus (Bus) cus var. dus", "genus", []string{"AUS,uuid-1"}},
+		{"Aus (Bus) cus var. dus", "infragenus", []string{"BUS,uuid-1"}},
+		{"Aus (Bus) cus var. dus", "species", []string{"CUS,uuid-1"}},
+		{"Aus (Bus) cus var. dus", "rank", []string{"VAR.,uuid-1"}},
+		{"Aus (Bus) cus var. dus", "subspecies", []string{"DUS,uuid-1"}},
+		{"Pomatomus", "uninomial", []string{"POMATOMUS,uuid-1"}},
+		{"Salix × rubens", "hybrid_char", []string{"×,uuid-1"}},
+		{"Salix × rubens", "rank", nil},
+		{"× Cupressocyparis leylandii", "hybrid_char", []string{"×,uuid-1"}},
+		{"Abies alba subsp. alba", "rank", []string{"SUBSP.,uuid-1"}},
+	}
+	for _, v := range tests {
+		res := wordRows(v.name, nil, nil)
+		if strings.Join(res[v.table], "|") != strings.Join(v.rows, "|") {
+			t.Errorf("%s: got %s %v, want %v", v.name, v.table, res[v.table],
+				v.rows)
+		}
+	}
+}
+
+func TestProcessCultivar(t *testing.T) {
+	// gnparser does not mark cultivars yet, so the position is made by hand
+	name := "Sarracenia flava 'Maxima'"
+	pn := util.ParsedName{ID: "uuid-1", Name: name,
+		Positions: []*pb.Position{{Type: "cultivar", Start: 17, End: 25}}}
+	ioJobs := make(chan ioJob, 10)
+	processWords(&pn, ioJobs, nil, nil)
+	close(ioJobs)
+	job := <-ioJobs
+	if job.Writer != "cultivar" || strings.Join(job.Row, ",") !=
+		"'MAXIMA',uuid-1" {
+		t.Errorf("got %s %v", job.Writer, job.Row)
+	}
+}
+
+func TestProcessAuthorWords(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []string
+	}{
+		{"Aus bus L.", []string{"LINNAEUS,uuid-1,L."}},
+		{"Aus bus Linné", []string{"LINNAEUS,uuid-1,Linné"}},
+		{"Aus bus L. Bolus", []string{"L.,uuid-1,L.", "BOLUS,uuid-1,Bolus"}},
+		{"Aus bus L. f.", []string{"LINNAEUS,uuid-1,L."}},
+		{"Aus bus Hook. & Arn.",
+			[]string{"HOOKER,uuid-1,Hook.", "ARNOTT,uuid-1,Arn."}},
+		{"Aus bus subsp. cus (L.) DC.",
+			[]string{"LINNAEUS,uuid-1,L.", "CANDOLLE,uuid-1,DC."}},
+	}
+	for _, v := range tests {
+		res := wordRows(v.name, nil, nil)["author_word"]
+		if strings.Join(res, "|") != strings.Join(v.rows, "|") {
+			t.Errorf("%s: got %v, want %v", v.name, res, v.rows)
+		}
+	}
+}
+
+func TestDuplicateNameStrings(t *testing.T) {
+	kv := testKV(t)
+	pn := util.ParsedName{ID: "uuid-1", IDOriginal: "1", Name: "Aus bus"}
+	wb := kv.NewWriteBatch()
+	if err := wb.Set(util.NameKey(pn.ID), pn.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	// gni IDs of a duplicated name-string point to the same UUID
+	for _, id := range []string{"1", "5"} {
+		if err := wb.Set(util.OriginalKey(id), []byte(pn.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	ioJobs := make(chan ioJob, 10)
+	processNameStringsRows([][]string{{"1", "Aus bus"}, {"5", "Aus bus"}},
+		ioJobs, nil, nil, kv)
+	close(ioJobs)
+	var rows [][]string
+	for job := range ioJobs {
+		if job.Writer == "name_strings" {
+			rows = append(rows, job.Row)
+		}
+	}
+	if len(rows) != 1 || rows[0][0] != "uuid-1" {
+		t.Errorf("got name_strings rows %v, want one row of uuid-1", rows)
+	}
+
+	for _, id := range []string{"1", "5"} {
+		res, err := parsedNameFromID(id, kv)
+		if err != nil || res.ID != "uuid-1" {
+			t.Errorf("gni ID %s: got %+v, %v, want uuid-1", id, res, err)
+		}
+	}
+}
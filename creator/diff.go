@@ -0,0 +1,331 @@
+package creator
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// diffSet is a kind of records compared between two releases. The first
+// field of every record is data_source_id.
+type diffSet struct {
+	name string
+	// file is a CSV file created by `gnidump create`. If it is empty,
+	// canonical forms are read from text files.
+	file string
+	// columns are compared columns of the file. If they are not given,
+	// columns that exist in both releases are compared, so files of releases
+	// made before new columns were added can be compared too.
+	columns []string
+}
+
+var diffSets = []diffSet{
+	{name: "names", file: "name_string_indices.csv",
+		columns: []string{"data_source_id", "name_string_id"}},
+	{name: "canonicals", columns: []string{"data_source_id", "canonical"}},
+	{name: "index", file: "name_string_indices.csv"},
+	{name: "vernaculars", file: "vernacular_string_indices.csv"},
+}
+
+// diffCount keeps numbers of added and removed records of every diffSet for
+// a data source.
+type diffCount struct {
+	added   []int
+	removed []int
+}
+
+// Diff compares files created by `gnidump create` in oldDir and newDir. For
+// every data source it finds names, canonical forms, name_string_indices
+// rows and vernacular_string_indices rows that were added or removed. The
+// changes are saved to CSV files in outDir, and a summary is written to w.
+func Diff(oldDir string, newDir string, outDir string, w io.Writer) error {
+	counts := make(map[string]*diffCount)
+	for i, set := range diffSets {
+		log.Println("Comparing", set.name)
+		err := diffSetFiles(i, set, oldDir, newDir, outDir, counts)
+		if err != nil {
+			return err
+		}
+	}
+	if err := saveDiffSummary(counts, outDir); err != nil {
+		return err
+	}
+	return printDiffSummary(counts, w)
+}
+
+func diffSetFiles(i int, set diffSet, oldDir string, newDir string,
+	outDir string, counts map[string]*diffCount) error {
+	header, err := diffColumns(set, oldDir, newDir)
+	if err != nil {
+		return err
+	}
+	oldRows := newSorter(outDir, true)
+	defer oldRows.close()
+	newRows := newSorter(outDir, true)
+	defer newRows.close()
+	if err = readDiffSet(set, header, oldDir, oldRows); err != nil {
+		return err
+	}
+	if err = readDiffSet(set, header, newDir, newRows); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(outDir, set.name+"_diff.csv"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if err = w.Write(append([]string{"change"}, header...)); err != nil {
+		return err
+	}
+
+	count := func(dsID string) *diffCount {
+		if _, ok := counts[dsID]; !ok {
+			counts[dsID] = &diffCount{added: make([]int, len(diffSets)),
+				removed: make([]int, len(diffSets))}
+		}
+		return counts[dsID]
+	}
+
+	nextOld, nextNew := oldRows.iter(), newRows.iter()
+	o, okOld := nextOld()
+	n, okNew := nextNew()
+	for okOld || okNew {
+		switch {
+		case okOld && (!okNew || lessRows(o, n)):
+			count(o[0]).removed[i]++
+			err = w.Write(append([]string{"removed"}, o...))
+			o, okOld = nextOld()
+		case okNew && (!okOld || lessRows(n, o)):
+			count(n[0]).added[i]++
+			err = w.Write(append([]string{"added"}, n...))
+			n, okNew = nextNew()
+		default:
+			o, okOld = nextOld()
+			n, okNew = nextNew()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// diffColumns returns columns of a diffSet that are compared. Columns that
+// exist only in one of the releases are logged and skipped.
+func diffColumns(set diffSet, oldDir string, newDir string) ([]string,
+	error) {
+	if set.columns != nil {
+		return set.columns, nil
+	}
+	oldHeader, err := csvHeader(filepath.Join(oldDir, set.file))
+	if err != nil {
+		return nil, err
+	}
+	newHeader, err := csvHeader(filepath.Join(newDir, set.file))
+	if err != nil {
+		return nil, err
+	}
+	inOld := make(map[string]bool)
+	for _, v := range oldHeader {
+		inOld[v] = true
+	}
+	var res []string
+	for _, v := range newHeader {
+		if inOld[v] {
+			res = append(res, v)
+			delete(inOld, v)
+		} else {
+			log.Printf("%s: column %s is only in the new release, "+
+				"it is not compared\n", set.name, v)
+		}
+	}
+	for _, v := range oldHeader {
+		if inOld[v] {
+			log.Printf("%s: column %s is only in the old release, "+
+				"it is not compared\n", set.name, v)
+		}
+	}
+	if len(res) == 0 || res[0] != "data_source_id" {
+		return nil, fmt.Errorf("%s: data_source_id must be the first column of "+
+			"%s in both releases", set.name, set.file)
+	}
+	return res, nil
+}
+
+// readDiffSet adds records of a diffSet from a release directory to a
+// sorter. Only the given columns are kept.
+func readDiffSet(set diffSet, columns []string, dir string, s *sorter) error {
+	if set.file == "" {
+		return readCanonicals(dir, s)
+	}
+	path := filepath.Join(dir, set.file)
+	header, err := csvHeader(path)
+	if err != nil {
+		return err
+	}
+	idx := make([]int, len(columns))
+	for i, c := range columns {
+		idx[i] = -1
+		for j, v := range header {
+			if v == c {
+				idx[i] = j
+			}
+		}
+		if idx[i] == -1 {
+			return fmt.Errorf("no column %s in %s", c, path)
+		}
+	}
+	return readCSVRows(path, func(row []string) {
+		res := make([]string, len(idx))
+		for i, j := range idx {
+			res[i] = row[j]
+		}
+		s.add(res)
+	})
+}
+
+// csvHeader returns the first row of a CSV file.
+func csvHeader(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return csv.NewReader(f).Read()
+}
+
+// readCSVRows calls fn for every row of a CSV file, except the header.
+func readCSVRows(path string, fn func(row []string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	if _, err = r.Read(); err != nil {
+		return err
+	}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(row)
+	}
+}
+
+// readCanonicals reads canonical forms with their data sources. The name of
+// the file contains the date when it was created.
+func readCanonicals(dir string, s *sorter) error {
+	paths, err := filepath.Glob(
+		filepath.Join(dir, "canonical_names_with_datasource_*.txt"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no canonical_names_with_datasource file in %s", dir)
+	}
+	sort.Strings(paths)
+	f, err := os.Open(paths[len(paths)-1])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Split(sc.Text(), "\t")
+		if len(fields) == 2 {
+			s.add([]string{fields[1], fields[0]})
+		}
+	}
+	return sc.Err()
+}
+
+// sortedDataSources returns data source IDs sorted as numbers.
+func sortedDataSources(counts map[string]*diffCount) []string {
+	res := make([]string, 0, len(counts))
+	for k := range counts {
+		res = append(res, k)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, errA := strconv.Atoi(res[i])
+		b, errB := strconv.Atoi(res[j])
+		if errA != nil || errB != nil {
+			return res[i] < res[j]
+		}
+		return a < b
+	})
+	return res
+}
+
+func saveDiffSummary(counts map[string]*diffCount, outDir string) error {
+	f, err := os.Create(filepath.Join(outDir, "summary.csv"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	err = w.Write([]string{"data_source_id", "kind", "added", "removed"})
+	if err != nil {
+		return err
+	}
+	for _, dsID := range sortedDataSources(counts) {
+		c := counts[dsID]
+		for i, set := range diffSets {
+			err = w.Write([]string{dsID, set.name, strconv.Itoa(c.added[i]),
+				strconv.Itoa(c.removed[i])})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func printDiffSummary(counts map[string]*diffCount, w io.Writer) error {
+	if len(counts) == 0 {
+		_, err := fmt.Fprintln(w, "No differences found")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "data source\t")
+	for _, set := range diffSets {
+		fmt.Fprintf(tw, "%s +\t%s -\t", set.name, set.name)
+	}
+	fmt.Fprintln(tw)
+
+	total := diffCount{added: make([]int, len(diffSets)),
+		removed: make([]int, len(diffSets))}
+	for _, dsID := range sortedDataSources(counts) {
+		c := counts[dsID]
+		fmt.Fprintf(tw, "%s\t", dsID)
+		for i := range diffSets {
+			fmt.Fprintf(tw, "%d\t%d\t", c.added[i], c.removed[i])
+			total.added[i] += c.added[i]
+			total.removed[i] += c.removed[i]
+		}
+		fmt.Fprintln(tw)
+	}
+	fmt.Fprint(tw, "total\t")
+	for i := range diffSets {
+		fmt.Fprintf(tw, "%d\t%d\t", total.added[i], total.removed[i])
+	}
+	fmt.Fprintln(tw)
+	return tw.Flush()
+}
@@ -0,0 +1,122 @@
+package creator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRelease creates files of a release as they are made by
+// `gnidump create`.
+func writeRelease(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func readDiffFile(t *testing.T, dir string, name string) []string {
+	bs, err := os.ReadFile(filepath.Join(dir, name+"_diff.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(bs)), "\n")
+}
+
+func TestDiff(t *testing.T) {
+	oldDir := writeRelease(t, map[string]string{
+		"name_string_indices.csv": "data_source_id,name_string_id,taxon_id\n" +
+			"1,u1,t1\n1,u2,t2\n3,u3,a3\n",
+		"vernacular_string_indices.csv": "data_source_id,taxon_id," +
+			"vernacular_string_id,language\n1,t1,v1,en\n",
+		"canonical_names_with_datasource_20190101.txt": "Aus bus\t1\n" +
+			"Cus dus\t3\n",
+	})
+	newDir := writeRelease(t, map[string]string{
+		"name_string_indices.csv": "data_source_id,name_string_id,taxon_id," +
+			"accepted_chain_depth\n" +
+			"1,u1,t1,0\n1,u2,t5,0\n4,u4,c4,0\n",
+		"vernacular_string_indices.csv": "data_source_id,taxon_id," +
+			"vernacular_string_id,language,language_orig\n1,t1,v1,en,English\n",
+		"canonical_names_with_datasource_20200101.txt": "Aus bus\t1\n" +
+			"Eus fus\t4\n",
+	})
+	outDir := t.TempDir()
+
+	var out bytes.Buffer
+	if err := Diff(oldDir, newDir, outDir, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		set  string
+		rows []string
+	}{
+		{"names", []string{"change,data_source_id,name_string_id",
+			"removed,3,u3", "added,4,u4"}},
+		{"canonicals", []string{"change,data_source_id,canonical",
+			"removed,3,Cus dus", "added,4,Eus fus"}},
+		{"index", []string{"change,data_source_id,name_string_id,taxon_id",
+			"removed,1,u2,t2", "added,1,u2,t5", "removed,3,u3,a3",
+			"added,4,u4,c4"}},
+		{"vernaculars", []string{"change,data_source_id,taxon_id," +
+			"vernacular_string_id,language"}},
+	}
+	for _, v := range tests {
+		rows := readDiffFile(t, outDir, v.set)
+		if strings.Join(rows, "\n") != strings.Join(v.rows, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", v.set, strings.Join(rows, "\n"),
+				strings.Join(v.rows, "\n"))
+		}
+	}
+
+	bs, err := os.ReadFile(filepath.Join(outDir, "summary.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"1,index,1,1", "1,vernaculars,0,0",
+		"3,names,0,1", "4,canonicals,1,0"} {
+		if !strings.Contains(string(bs), v+"\n") {
+			t.Errorf("no '%s' in summary.csv:\n%s", v, bs)
+		}
+	}
+	if !strings.Contains(out.String(), "total") {
+		t.Errorf("no total in summary:\n%s", out.String())
+	}
+}
+
+func TestDiffNoChanges(t *testing.T) {
+	files := map[string]string{
+		"name_string_indices.csv": "data_source_id,name_string_id\n1,u1\n",
+	}
+	files["vernacular_string_indices.csv"] = "data_source_id,taxon_id\n1,t1\n"
+	files["canonical_names_with_datasource_20200101.txt"] = "Aus bus\t1\n"
+	var out bytes.Buffer
+	err := Diff(writeRelease(t, files), writeRelease(t, files), t.TempDir(),
+		&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(out.String()) != "No differences found" {
+		t.Errorf("got %s", out.String())
+	}
+}
+
+func TestDiffColumns(t *testing.T) {
+	oldDir := writeRelease(t, map[string]string{
+		"name_string_indices.csv": "name_string_id,data_source_id\n",
+	})
+	newDir := writeRelease(t, map[string]string{
+		"name_string_indices.csv": "name_string_id,data_source_id\n",
+	})
+	set := diffSet{name: "index", file: "name_string_indices.csv"}
+	if _, err := diffColumns(set, oldDir, newDir); err == nil {
+		t.Error("no error when data_source_id is not the first column")
+	}
+}
@@ -0,0 +1,78 @@
+package creator
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dimus/gnidump/util"
+)
+
+// languagesCSV is the table of ISO 639-3 languages with their ISO 639-1 and
+// ISO 639-2/B codes, made from iso-codes data of Debian project. Some common
+// names of languages in their own languages are added to other_names.
+//
+//go:embed languages.csv
+var languagesCSV string
+
+// languages maps lowercase codes and names of languages to normalized codes.
+var languages = languageCodes()
+
+func languageCodes() map[string]string {
+	rows, err := csv.NewReader(strings.NewReader(languagesCSV)).ReadAll()
+	util.Check(err)
+	res := make(map[string]string)
+	add := func(key string, code string) {
+		key = strings.ToLower(key)
+		if _, ok := res[key]; key != "" && !ok {
+			res[key] = code
+		}
+	}
+	// codes take precedence over names, for example "en" is English, not the
+	// En language
+	for _, i := range []int{1, 0, 2, 3, 4} {
+		for _, row := range rows[1:] {
+			code := row[0]
+			if row[1] != "" {
+				code = row[1]
+			}
+			for _, k := range strings.Split(row[i], "|") {
+				add(k, code)
+			}
+		}
+	}
+	return res
+}
+
+// normalizeLanguage returns the ISO 639-1 code of a language, or its
+// ISO 639-3 code if the language has no ISO 639-1 code. The language can be
+// given as ISO 639-1, ISO 639-2/B or ISO 639-3 code, or as a name. If the
+// language is not known, it returns false.
+func normalizeLanguage(lang string) (string, bool) {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	code, ok := languages[lang]
+	return code, ok
+}
+
+// saveUnmappedLanguages writes languages that were not recognized to a report,
+// with the number of vernacular names for each of them.
+func saveUnmappedLanguages(unmapped map[string]int) {
+	r := util.NewReport("unmapped_languages", []string{"language", "count"},
+		false)
+	defer r.Close()
+	langs := make([]string, 0, len(unmapped))
+	for k := range unmapped {
+		langs = append(langs, k)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if unmapped[langs[i]] != unmapped[langs[j]] {
+			return unmapped[langs[i]] > unmapped[langs[j]]
+		}
+		return langs[i] < langs[j]
+	})
+	for _, l := range langs {
+		r.Write([]string{l, strconv.Itoa(unmapped[l])})
+	}
+}
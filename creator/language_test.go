@@ -0,0 +1,28 @@
+package creator
+
+import "testing"
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		lang, code string
+		ok         bool
+	}{
+		{"English", "en", true},
+		{" en ", "en", true},
+		{"eng", "en", true},
+		{"ger", "de", true},
+		{"Deutsch", "de", true},
+		{"Castilian", "es", true},
+		{"Ghotuo", "aaa", true},
+		{"aaa", "aaa", true},
+		{"Klingon", "tlh", true},
+		{"Elvish", "", false},
+		{"", "", false},
+	}
+	for _, v := range tests {
+		code, ok := normalizeLanguage(v.lang)
+		if code != v.code || ok != v.ok {
+			t.Errorf("%s: got %s %t, want %s %t", v.lang, code, ok, v.code, v.ok)
+		}
+	}
+}
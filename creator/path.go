@@ -0,0 +1,234 @@
+package creator
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dimus/gnidump/util"
+)
+
+// PathMode tells what to do with inconsistent classification paths.
+type PathMode int
+
+// Ways to deal with inconsistent classification paths.
+const (
+	// PathsReport only reports problems.
+	PathsReport PathMode = iota
+	// PathsDrop removes inconsistent classification paths.
+	PathsDrop
+	// PathsRepair fixes problems when it is possible, and removes paths that
+	// cannot be fixed.
+	PathsRepair
+)
+
+var pathModeStrings = []string{"report", "drop", "repair"}
+
+func (m PathMode) String() string {
+	if m < 0 || int(m) >= len(pathModeStrings) {
+		return "unknown"
+	}
+	return pathModeStrings[m]
+}
+
+// NewPathMode returns PathMode by its name.
+func NewPathMode(s string) (PathMode, error) {
+	for i, v := range pathModeStrings {
+		if v == s {
+			return PathMode(i), nil
+		}
+	}
+	return PathsReport, fmt.Errorf("unknown classification path mode '%s'", s)
+}
+
+// Problems of classification paths.
+const (
+	pathCountMismatch = "count_mismatch"
+	pathLastID        = "last_id_mismatch"
+	pathEmptyRank     = "empty_rank"
+)
+
+// classification keeps the elements of a classification path.
+type classification struct {
+	path  []string
+	ids   []string
+	ranks []string
+}
+
+func newClassification(path string, ids string, ranks string) classification {
+	return classification{path: splitPath(path), ids: splitPath(ids),
+		ranks: splitPath(ranks)}
+}
+
+func splitPath(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "|")
+}
+
+func (c classification) empty() bool {
+	return len(c.path) == 0 && len(c.ids) == 0 && len(c.ranks) == 0
+}
+
+// problems checks that path, its IDs and ranks have the same number of
+// elements, that the last ID belongs to the taxon (or to its accepted taxon
+// for synonyms), and that all ranks are given.
+func (c classification) problems(taxonID string) []string {
+	if c.empty() {
+		return nil
+	}
+	var res []string
+	if len(c.path) != len(c.ids) || len(c.path) != len(c.ranks) {
+		res = append(res, pathCountMismatch)
+	}
+	if len(c.ids) == 0 || c.ids[len(c.ids)-1] != taxonID {
+		res = append(res, pathLastID)
+	}
+	for _, r := range c.ranks {
+		if r == "" {
+			res = append(res, pathEmptyRank)
+			break
+		}
+	}
+	if len(c.ranks) == 0 {
+		res = append(res, pathEmptyRank)
+	}
+	return res
+}
+
+// repair cuts elements that do not line up, cuts elements below the taxon,
+// and uses rank of the taxon for the last element if it is missing.
+func (c classification) repair(taxonID string, rank string) classification {
+	n := len(c.path)
+	if len(c.ids) < n {
+		n = len(c.ids)
+	}
+	if len(c.ranks) == 0 {
+		c.ranks = make([]string, n)
+	}
+	if len(c.ranks) < n {
+		n = len(c.ranks)
+	}
+	for i, id := range c.ids[:n] {
+		if id == taxonID {
+			n = i + 1
+			break
+		}
+	}
+	c.path, c.ids, c.ranks = c.path[:n], c.ids[:n], c.ranks[:n]
+	if n > 0 && c.ids[n-1] == taxonID && c.ranks[n-1] == "" {
+		c.ranks[n-1] = rank
+	}
+	return c
+}
+
+// acceptedID returns ID of the accepted taxon of a name_string_indices row.
+// If accepted_taxon_id is empty, the last ID of the original classification
+// path is the accepted taxon. If it differs from taxon_id, the taxon is a
+// synonym.
+func acceptedID(row []string) string {
+	if row[8] != "" {
+		return row[8]
+	}
+	return lastPathID(row[10], row[3])
+}
+
+// checkPath validates the classification path of a name_string_indices row,
+// and returns path, path IDs and path ranks changed according to the mode,
+// found problems, and what was done with the path. The path is checked
+// against the accepted taxon found before the path is changed.
+func checkPath(row []string, mode PathMode) ([]string, []string, string) {
+	fields := []string{row[9], row[10], row[11]}
+	taxonID := acceptedID(row)
+	c := newClassification(row[9], row[10], row[11])
+	problems := c.problems(taxonID)
+	if len(problems) == 0 || mode == PathsReport {
+		return fields, problems, "kept"
+	}
+	if mode == PathsRepair {
+		c = c.repair(taxonID, row[7])
+		if len(c.problems(taxonID)) == 0 {
+			return []string{strings.Join(c.path, "|"), strings.Join(c.ids, "|"),
+				strings.Join(c.ranks, "|")}, problems, "repaired"
+		}
+	}
+	return []string{"", "", ""}, problems, "dropped"
+}
+
+// pathReport saves problems of classification paths and counts them for
+// every data source.
+type pathReport struct {
+	mode   PathMode
+	report *util.Report
+	mu     sync.Mutex
+	counts map[string]map[string]int
+}
+
+func newPathReport(mode PathMode) *pathReport {
+	return &pathReport{
+		mode: mode,
+		report: util.NewReport("classification_paths",
+			[]string{"data_source_id", "taxon_id", "problems", "action",
+				"classification_path", "classification_path_ids",
+				"classification_path_ranks"}, false),
+		counts: make(map[string]map[string]int),
+	}
+}
+
+// check validates the classification path of a name_string_indices row and
+// returns the path fields that should be exported.
+func (pr *pathReport) check(row []string) []string {
+	fields, problems, action := checkPath(row, pr.mode)
+	if len(problems) == 0 {
+		return fields
+	}
+	pr.report.Write([]string{row[0], row[3], strings.Join(problems, "|"),
+		action, row[9], row[10], row[11]})
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	if _, ok := pr.counts[row[0]]; !ok {
+		pr.counts[row[0]] = make(map[string]int)
+	}
+	for _, p := range problems {
+		pr.counts[row[0]][p]++
+	}
+	pr.counts[row[0]][action]++
+	return fields
+}
+
+// close saves the report, and writes numbers of problems in every data
+// source to classification_paths_summary report.
+func (pr *pathReport) close() {
+	pr.report.Close()
+	if pr.report.Count() == 0 {
+		return
+	}
+	log.Printf("Found %d inconsistent classification paths, "+
+		"see classification_paths.csv report\n", pr.report.Count())
+
+	summary := util.NewReport("classification_paths_summary",
+		[]string{"data_source_id", "problem", "count"}, false)
+	defer summary.Close()
+	ids := make([]int, 0, len(pr.counts))
+	for k := range pr.counts {
+		id, err := strconv.Atoi(k)
+		util.Check(err)
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	keys := append([]string{pathCountMismatch, pathLastID, pathEmptyRank},
+		"kept", "repaired", "dropped")
+	for _, id := range ids {
+		ds := strconv.Itoa(id)
+		for _, k := range keys {
+			if n := pr.counts[ds][k]; n > 0 {
+				summary.Write([]string{ds, k, strconv.Itoa(n)})
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package creator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckPath(t *testing.T) {
+	tests := []struct {
+		taxonID, accepted, rank, path, ids, ranks string
+		mode                                      PathMode
+		fields                                    []string
+		problems                                  []string
+		action                                    string
+	}{
+		{"t2", "", "species", "Aus|Aus bus", "t1|t2", "genus|species",
+			PathsRepair, []string{"Aus|Aus bus", "t1|t2", "genus|species"},
+			nil, "kept"},
+		{"t2", "", "species", "", "", "", PathsDrop,
+			[]string{"", "", ""}, nil, "kept"},
+		{"t3", "t2", "species", "Aus|Aus bus", "t1|t2", "genus|species",
+			PathsDrop, []string{"Aus|Aus bus", "t1|t2", "genus|species"},
+			nil, "kept"},
+		{"t2", "", "species", "Aus|Aus bus", "t1|t2", "genus",
+			PathsReport, []string{"Aus|Aus bus", "t1|t2", "genus"},
+			[]string{pathCountMismatch}, "kept"},
+		{"t2", "", "species", "Aus|Aus bus", "t1|t2", "genus",
+			PathsDrop, []string{"", "", ""},
+			[]string{pathCountMismatch}, "dropped"},
+		{"t2", "", "species", "Aus|Aus bus", "t1|t2", "genus|",
+			PathsRepair, []string{"Aus|Aus bus", "t1|t2", "genus|species"},
+			[]string{pathEmptyRank}, "repaired"},
+		{"t9", "t2", "species", "Aus|Aus bus|Aus bus cus", "t1|t2|t3",
+			"genus|species|subspecies", PathsRepair,
+			[]string{"Aus|Aus bus", "t1|t2", "genus|species"},
+			[]string{pathLastID}, "repaired"},
+		{"t4", "t5", "species", "Aus|Aus bus", "t1|t2", "genus|species",
+			PathsRepair, []string{"", "", ""}, []string{pathLastID}, "dropped"},
+		// synonyms with empty accepted_taxon_id have the accepted taxon at the
+		// end of their paths
+		{"t3", "", "species", "Aus|Aus bus", "t1|t2", "genus|species",
+			PathsReport, []string{"Aus|Aus bus", "t1|t2", "genus|species"},
+			nil, "kept"},
+		{"t3", "", "species", "Aus|Aus bus", "t1|t2", "genus|species",
+			PathsDrop, []string{"Aus|Aus bus", "t1|t2", "genus|species"},
+			nil, "kept"},
+		{"t3", "", "species", "Aus|Aus bus", "t1|t2", "genus|", PathsRepair,
+			[]string{"Aus|Aus bus", "t1|t2", "genus|species"},
+			[]string{pathEmptyRank}, "repaired"},
+		{"t3", "", "species", "Aus|Aus bus", "t1|t2", "genus", PathsDrop,
+			[]string{"", "", ""}, []string{pathCountMismatch}, "dropped"},
+	}
+	for i, v := range tests {
+		row := []string{"1", "10", "", v.taxonID, "", "", "", v.rank, v.accepted,
+			v.path, v.ids, v.ranks}
+		fields, problems, action := checkPath(row, v.mode)
+		if !reflect.DeepEqual(fields, v.fields) ||
+			!reflect.DeepEqual(problems, v.problems) || action != v.action {
+			t.Errorf("%d: got %v %v %s, want %v %v %s", i, fields, problems, action,
+				v.fields, v.problems, v.action)
+		}
+	}
+}
+
+func TestAcceptedID(t *testing.T) {
+	tests := []struct {
+		taxonID, accepted, ids, res string
+	}{
+		{"t2", "", "t1|t2", "t2"},
+		{"t2", "", "", "t2"},
+		{"t3", "", "t1|t2", "t2"},
+		{"t3", "t5", "t1|t2", "t5"},
+	}
+	for _, v := range tests {
+		row := []string{"1", "10", "", v.taxonID, "", "", "", "species",
+			v.accepted, "", v.ids, ""}
+		if res := acceptedID(row); res != v.res {
+			t.Errorf("%s %s %s: got %s, want %s", v.taxonID, v.accepted, v.ids,
+				res, v.res)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package creator
+
+import (
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/dimus/gnidump/util"
+)
+
+// Reasons for moving rows of gni data to quarantine.
+const (
+	// reasonNameNotFound means that a name-string is not found in the
+	// key-value store created by converter.
+	reasonNameNotFound = "name_not_found"
+	// reasonVernacularNotFound means that a vernacular string is not found in
+	// gni vernacular_strings.
+	reasonVernacularNotFound = "vernacular_not_found"
+)
+
+// gniColumns are columns of gni tables as they are dumped to CSV files.
+var gniColumns = map[string][]string{
+	"name_strings": {"id", "name"},
+	"name_string_indices": {"data_source_id", "name_string_id", "url",
+		"taxon_id", "global_id", "local_id", "nomenclatural_code_id", "rank",
+		"accepted_taxon_id", "classification_path", "classification_path_ids",
+		"classification_path_ranks"},
+	"vernacular_string_indices": {"data_source_id", "taxon_id",
+		"vernacular_string_id", "language", "locality", "country_code"},
+}
+
+// quarantine saves rows of gni tables that cannot be exported to gnindex.
+// Rows of every gni table go to their own CSV file in util.QuarantineDir,
+// together with the reason and the name of the table.
+type quarantine struct {
+	files  map[string]*util.Report
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newQuarantine() *quarantine {
+	q := &quarantine{files: make(map[string]*util.Report),
+		counts: make(map[string]int)}
+	for table, columns := range gniColumns {
+		header := append([]string{"reason", "source_table"}, columns...)
+		q.files[table] = util.NewQuarantine(table, header)
+	}
+	return q
+}
+
+// add moves a row of a gni table to quarantine.
+func (q *quarantine) add(table string, reason string, row []string) {
+	q.files[table].Write(append([]string{reason, table}, row...))
+	q.mu.Lock()
+	q.counts[table+": "+reason]++
+	q.mu.Unlock()
+}
+
+// close saves quarantine files and logs the number of rows for every table
+// and reason.
+func (q *quarantine) close() {
+	for _, f := range q.files {
+		f.Close()
+	}
+	if len(q.counts) == 0 {
+		log.Println("No rows were moved to quarantine")
+		return
+	}
+	keys := make([]string, 0, len(q.counts))
+	for k := range q.counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	log.Printf("Rows moved to quarantine (see %s):\n", util.QuarantineDir)
+	for _, k := range keys {
+		log.Printf("  %s: %d\n", k, q.counts[k])
+	}
+}
@@ -0,0 +1,206 @@
+package creator
+
+import (
+	"container/heap"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/dimus/gnidump/util"
+)
+
+// sortChunkSize is the number of rows sorted in memory at once.
+const sortChunkSize = 1000000
+
+// sorter sorts rows that do not fit into memory. Rows are sorted in chunks,
+// every chunk is saved to a temporary file, and the files are merged at the
+// end.
+type sorter struct {
+	dir       string
+	chunkSize int
+	unique    bool
+	rows      [][]string
+	files     []string
+	open      []*os.File
+}
+
+// newSorter creates a sorter that keeps temporary files in a new directory
+// inside of dir. If unique is true, repeated rows are removed.
+func newSorter(dir string, unique bool) *sorter {
+	tmp, err := os.MkdirTemp(dir, "sort_")
+	util.Check(err)
+	return &sorter{dir: tmp, chunkSize: sortChunkSize, unique: unique}
+}
+
+func (s *sorter) add(row []string) {
+	s.rows = append(s.rows, row)
+	if len(s.rows) >= s.chunkSize {
+		s.flush()
+	}
+}
+
+// flush sorts rows in memory and saves them to a temporary file.
+func (s *sorter) flush() {
+	if len(s.rows) == 0 {
+		return
+	}
+	sort.Slice(s.rows, func(i, j int) bool {
+		return lessRows(s.rows[i], s.rows[j])
+	})
+	path := filepath.Join(s.dir, fmt.Sprintf("chunk_%d.csv", len(s.files)))
+	f, err := os.Create(path)
+	util.Check(err)
+	w := csv.NewWriter(f)
+	var prev []string
+	for _, row := range s.rows {
+		if s.unique && prev != nil && !lessRows(prev, row) {
+			continue
+		}
+		err = w.Write(row)
+		util.Check(err)
+		prev = row
+	}
+	w.Flush()
+	util.Check(w.Error())
+	err = f.Close()
+	util.Check(err)
+	s.files = append(s.files, path)
+	s.rows = s.rows[:0]
+}
+
+// each merges sorted chunks and calls fn for every row in sorted order.
+func (s *sorter) each(fn func(row []string)) {
+	next := s.iter()
+	for row, ok := next(); ok; row, ok = next() {
+		fn(row)
+	}
+}
+
+// iter merges sorted chunks and returns a function that gives one row at a
+// time in sorted order. The function returns false when all rows are read.
+func (s *sorter) iter() func() ([]string, bool) {
+	s.flush()
+	h := &mergeHeap{}
+	for _, path := range s.files {
+		f, err := os.Open(path)
+		util.Check(err)
+		s.open = append(s.open, f)
+		c := &mergeChunk{r: csv.NewReader(f)}
+		if c.next() {
+			h.chunks = append(h.chunks, c)
+		}
+	}
+	heap.Init(h)
+
+	var prev []string
+	return func() ([]string, bool) {
+		for h.Len() > 0 {
+			c := h.chunks[0]
+			row := c.row
+			if c.next() {
+				heap.Fix(h, 0)
+			} else {
+				heap.Pop(h)
+			}
+			if !s.unique || prev == nil || lessRows(prev, row) {
+				prev = row
+				return row, true
+			}
+		}
+		return nil, false
+	}
+}
+
+// close removes temporary files.
+func (s *sorter) close() {
+	for _, f := range s.open {
+		f.Close()
+	}
+	err := os.RemoveAll(s.dir)
+	util.Check(err)
+}
+
+func lessRows(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// mergeChunk is a sorted chunk file with its current row.
+type mergeChunk struct {
+	r   *csv.Reader
+	row []string
+}
+
+func (c *mergeChunk) next() bool {
+	row, err := c.r.Read()
+	if err == io.EOF {
+		return false
+	}
+	util.Check(err)
+	c.row = row
+	return true
+}
+
+// mergeHeap keeps chunks ordered by their current rows.
+type mergeHeap struct {
+	chunks []*mergeChunk
+}
+
+func (h *mergeHeap) Len() int { return len(h.chunks) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	return lessRows(h.chunks[i].row, h.chunks[j].row)
+}
+
+func (h *mergeHeap) Swap(i, j int) {
+	h.chunks[i], h.chunks[j] = h.chunks[j], h.chunks[i]
+}
+
+func (h *mergeHeap) Push(x interface{}) {
+	h.chunks = append(h.chunks, x.(*mergeChunk))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	c := h.chunks[len(h.chunks)-1]
+	h.chunks = h.chunks[:len(h.chunks)-1]
+	return c
+}
+
+// tableSortChunkSize is the number of rows of a table sorted in memory at
+// once. It is smaller than sortChunkSize, because all tables are sorted at
+// the same time.
+const tableSortChunkSize = 200000
+
+// sortedWriter collects rows of a table and writes them to a CSV file in
+// sorted order.
+type sortedWriter struct {
+	s *sorter
+	w *csv.Writer
+}
+
+func newSortedWriter(w *csv.Writer) *sortedWriter {
+	s := newSorter(util.GnindexDir, false)
+	s.chunkSize = tableSortChunkSize
+	return &sortedWriter{s: s, w: w}
+}
+
+func (sw *sortedWriter) Write(row []string) error {
+	sw.s.add(row)
+	return nil
+}
+
+// flush writes sorted rows to the CSV file and removes temporary files.
+func (sw *sortedWriter) flush() {
+	sw.s.each(func(row []string) {
+		err := sw.w.Write(row)
+		util.Check(err)
+	})
+	sw.s.close()
+}
@@ -0,0 +1,31 @@
+package creator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSorter(t *testing.T) {
+	rows := [][]string{{"Bubo", "2"}, {"Aus", "1"}, {"Bubo", "1"},
+		{"Aus", "1"}, {"Aus bus", "3"}, {"Bubo", "2"}, {"Abies", "1"}}
+	for _, unique := range []bool{true, false} {
+		s := newSorter(t.TempDir(), unique)
+		s.chunkSize = 2
+		for _, row := range rows {
+			s.add(row)
+		}
+		var res [][]string
+		s.each(func(row []string) { res = append(res, row) })
+		s.close()
+
+		want := [][]string{{"Abies", "1"}, {"Aus", "1"}, {"Aus bus", "3"},
+			{"Bubo", "1"}, {"Bubo", "2"}}
+		if !unique {
+			want = [][]string{{"Abies", "1"}, {"Aus", "1"}, {"Aus", "1"},
+				{"Aus bus", "3"}, {"Bubo", "1"}, {"Bubo", "2"}, {"Bubo", "2"}}
+		}
+		if !reflect.DeepEqual(res, want) {
+			t.Errorf("unique %t: got %v, want %v", unique, res, want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package creator
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger"
+	"github.com/dimus/gnidump/util"
+)
+
+// Nomenclatural codes as they are numbered in gni nomenclatural_code_id
+// field.
+const (
+	codeZoological = "1"
+	codeBotanical  = "2"
+)
+
+// startYears are the first years of nomenclature under a code: Species
+// Plantarum of Linnaeus for botanical names, and the 10th edition of Systema
+// Naturae for zoological names. Other codes use defaultStartYear.
+var startYears = map[string]int{
+	codeBotanical:  1753,
+	codeZoological: 1758,
+}
+
+const defaultStartYear = 1753
+
+// Reasons for rejecting years of names.
+const (
+	yearMalformed   = "malformed"
+	yearBeforeStart = "before_start"
+	yearInFuture    = "in_future"
+)
+
+// yearRe finds a year in a year word of gnparser. The last digit of an
+// approximate year can be replaced by a question mark, like in "185?". Years
+// can be in parentheses or brackets, or can be the first year of a range.
+var yearRe = regexp.MustCompile(`^[\[(]?(\d{3})([\d?])`)
+
+// dataSourceCodes parses default nomenclatural codes of data sources given as
+// "data_source_id:nomenclatural_code_id" pairs separated by commas, for
+// example "1:1,3:2". The default is used for name_string_indices rows
+// without nomenclatural_code_id.
+func dataSourceCodes(s string) (map[string]string, error) {
+	res := make(map[string]string)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		fields := strings.Split(v, ":")
+		if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+			return nil, fmt.Errorf("cannot parse nomenclatural code '%s', "+
+				"use data_source_id:nomenclatural_code_id", v)
+		}
+		res[strings.TrimSpace(fields[0])] = strings.TrimSpace(fields[1])
+	}
+	return res, nil
+}
+
+// rowCode returns nomenclatural code of a name_string_indices row, or the
+// default code of its data source.
+func rowCode(row []string, defaults map[string]string) string {
+	if row[6] != "" {
+		return row[6]
+	}
+	return defaults[row[0]]
+}
+
+// nameCodes returns nomenclatural codes used with a name-string in gni data
+// sources.
+func nameCodes(uuid string, txn *badger.Txn) []string {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	prefix := util.CodeKey(uuid, "")
+	var res []string
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		res = append(res, string(it.Item().Key()[len(prefix):]))
+	}
+	return res
+}
+
+// startYear returns the first acceptable year for a name used with the given
+// nomenclatural codes. If the name is used with several codes, the earliest
+// year is taken.
+func startYear(codes []string) int {
+	if len(codes) == 0 {
+		return defaultStartYear
+	}
+	res := 0
+	for _, c := range codes {
+		yr, ok := startYears[c]
+		if !ok {
+			yr = defaultStartYear
+		}
+		if res == 0 || yr < res {
+			res = yr
+		}
+	}
+	return res
+}
+
+// checkYear finds the year in a year word of a name and checks that it is
+// not earlier than the start of nomenclature and not in the future. An
+// unknown last digit of a year is taken as 0, so "185?" becomes 1850. It
+// returns the year, or the reason to reject it.
+func checkYear(word string, codes []string, now time.Time) (string, string) {
+	m := yearRe.FindStringSubmatch(word)
+	if m == nil {
+		return "", yearMalformed
+	}
+	yrStr := m[1] + strings.Replace(m[2], "?", "0", 1)
+	yr, err := strconv.Atoi(yrStr)
+	util.Check(err)
+	switch {
+	case yr < startYear(codes):
+		return "", yearBeforeStart
+	case yr > now.Year()+2:
+		return "", yearInFuture
+	}
+	return yrStr, ""
+}
+
+// yearReport saves years of names that did not pass validation.
+type yearReport struct {
+	report *util.Report
+}
+
+func newYearReport() *yearReport {
+	return &yearReport{report: util.NewReport("rejected_years",
+		[]string{"name_uuid", "name", "year", "nomenclatural_codes", "reason"},
+		false)}
+}
+
+func (yr *yearReport) add(pn *util.ParsedName, word string, codes []string,
+	reason string) {
+	yr.report.Write([]string{pn.ID, pn.Name, word, strings.Join(codes, "|"),
+		reason})
+}
+
+func (yr *yearReport) close() {
+	yr.report.Close()
+	if n := yr.report.Count(); n > 0 {
+		log.Printf("Rejected %d years of names, see rejected_years.csv report\n",
+			n)
+	}
+}
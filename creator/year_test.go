@@ -0,0 +1,127 @@
+package creator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimus/gnidump/util"
+)
+
+func TestCheckYear(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		word   string
+		codes  []string
+		year   string
+		reason string
+	}{
+		{"1850", nil, "1850", ""},
+		{"1850?", nil, "1850", ""},
+		{"1850a", []string{codeZoological}, "1850", ""},
+		{"1753", []string{codeBotanical}, "1753", ""},
+		{"1753", []string{codeZoological}, "", yearBeforeStart},
+		{"1753", []string{codeZoological, codeBotanical}, "1753", ""},
+		{"1753", nil, "1753", ""},
+		{"1752", nil, "", yearBeforeStart},
+		{"1758", []string{codeZoological}, "1758", ""},
+		{"2022", nil, "2022", ""},
+		{"2023", nil, "", yearInFuture},
+		{"185?", nil, "1850", ""},
+		{"175?", []string{codeZoological}, "", yearBeforeStart},
+		{"[1850]", nil, "1850", ""},
+		{"(1850)", nil, "1850", ""},
+		{"1850-1855", nil, "1850", ""},
+		{"1850–55", nil, "1850", ""},
+		{"ABCD", nil, "", yearMalformed},
+	}
+	for _, v := range tests {
+		yr, reason := checkYear(v.word, v.codes, now)
+		if yr != v.year || reason != v.reason {
+			t.Errorf("%s %v: got '%s' '%s', want '%s' '%s'", v.word, v.codes, yr,
+				reason, v.year, v.reason)
+		}
+	}
+}
+
+func TestDataSourceCodes(t *testing.T) {
+	codes, err := dataSourceCodes("1:1, 3:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 2 || codes["1"] != "1" || codes["3"] != "2" {
+		t.Errorf("wrong codes %v", codes)
+	}
+	if codes, err = dataSourceCodes(""); err != nil || len(codes) != 0 {
+		t.Errorf("empty codes: %v %v", codes, err)
+	}
+	if _, err = dataSourceCodes("1"); err == nil {
+		t.Error("no error for '1'")
+	}
+	row := []string{"3", "8", "", "a8", "", "", "", "species"}
+	if c := rowCode(row, codes); c != "" {
+		t.Errorf("got code '%s' without defaults", c)
+	}
+	codes, _ = dataSourceCodes("3:2")
+	if c := rowCode(row, codes); c != codeBotanical {
+		t.Errorf("got code '%s', want default '%s'", c, codeBotanical)
+	}
+	row[6] = codeZoological
+	if c := rowCode(row, codes); c != codeZoological {
+		t.Errorf("got code '%s', want '%s'", c, codeZoological)
+	}
+}
+
+func TestProcessYears(t *testing.T) {
+	kv := testKV(t)
+	wb := kv.NewWriteBatch()
+	err := wb.Set(util.CodeKey("uuid-1", codeZoological), []byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wb.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	txn := kv.NewTransaction(false)
+	defer txn.Discard()
+
+	path := filepath.Join(t.TempDir(), "years.csv")
+	years := &yearReport{report: util.NewReportFile(path,
+		[]string{"name_uuid", "name", "year", "nomenclatural_codes", "reason"})}
+
+	tests := []struct {
+		name string
+		rows []string
+	}{
+		{"Aus bus Smith 1850", []string{"1850,uuid-1,false"}},
+		{"Aus bus Smith 1850?", []string{"1850,uuid-1,true"}},
+		{"Aus bus Smith 185?", []string{"1850,uuid-1,true"}},
+		{"Aus bus Smith [1850]", []string{"1850,uuid-1,true"}},
+		{"Aus bus Smith (1850)", []string{"1850,uuid-1,true"}},
+		{"Aus bus Smith 1850-1855", []string{"1850,uuid-1,true"}},
+		{"Aus bus Smith 1850a", []string{"1850,uuid-1,false"}},
+		{"Aus bus Smith 1753", nil},
+		{"Aus bus Smith 175?", nil},
+	}
+	for _, v := range tests {
+		res := wordRows(v.name, years, txn)["year"]
+		if strings.Join(res, "|") != strings.Join(v.rows, "|") {
+			t.Errorf("%s: got %v, want %v", v.name, res, v.rows)
+		}
+	}
+	years.report.Close()
+
+	rejected := readReport(t, path)
+	want := []string{"uuid-1,Aus bus Smith 1753,1753,1,before_start",
+		"uuid-1,Aus bus Smith 175?,175?,1,before_start"}
+	if len(rejected) != len(want) {
+		t.Fatalf("got %d rejected years, want %d: %v", len(rejected), len(want),
+			rejected)
+	}
+	for i, w := range want {
+		if r := strings.Join(rejected[i], ","); r != w {
+			t.Errorf("got %s, want %s", r, w)
+		}
+	}
+}
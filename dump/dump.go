@@ -35,6 +35,21 @@ func Prepare() {
 		util.Check(err)
 	}
 	util.Check(err)
+	if _, err := os.Stat(util.ParseCacheDir); os.IsNotExist(err) {
+		err := os.Mkdir(util.ParseCacheDir, 0777)
+		util.Check(err)
+	}
+	util.Check(err)
+	if _, err := os.Stat(util.ReportDir); os.IsNotExist(err) {
+		err := os.Mkdir(util.ReportDir, 0777)
+		util.Check(err)
+	}
+	util.Check(err)
+	if _, err := os.Stat(util.QuarantineDir); os.IsNotExist(err) {
+		err := os.Mkdir(util.QuarantineDir, 0777)
+		util.Check(err)
+	}
+	util.Check(err)
 }
 
 // Tables creates csv files from the Global Names Index data.
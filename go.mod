@@ -6,8 +6,10 @@ require (
 	github.com/go-sql-driver/mysql v0.0.0-20170822214809-26471af196a1
 	github.com/google/gofuzz v0.0.0-20170612174753-24818f796faf // indirect
 	github.com/json-iterator/go v1.1.5
+	github.com/lib/pq v1.3.0
 	github.com/satori/go.uuid v1.2.0
 	gitlab.com/gogna/gnparser v0.12.1-0.20191119201732-de6682f10f33
+	golang.org/x/text v0.3.1-0.20180807135948-17ff2d5776d2
 )
 
-go 1.13
+go 1.16
@@ -0,0 +1,192 @@
+// Package loader streams rows into gnindex PostgreSQL database using COPY
+// protocol.
+package loader
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dimus/gnidump/util"
+	"github.com/lib/pq"
+)
+
+// progressStep is the number of rows between progress messages.
+const progressStep = 1000000
+
+// Table describes columns of a gnindex table that receive data.
+type Table struct {
+	Name    string
+	Columns []string
+	// NotNull columns keep empty strings. In other columns empty strings are
+	// loaded as NULL, the same way COPY treats empty values in CSV files.
+	NotNull []string
+}
+
+// DB opens connection to gnindex database using GNINDEX_* environment
+// variables.
+func DB() *sql.DB {
+	env := util.EnvVars()
+	url := fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		env["pg_host"], env["pg_port"], env["pg_user"], env["pg_password"],
+		env["pg_database"])
+	db, err := sql.Open("postgres", url)
+	util.Check(err)
+	return db
+}
+
+// Truncate removes all rows from a table within a transaction.
+func Truncate(tx *sql.Tx, name string) error {
+	_, err := tx.Exec("TRUNCATE TABLE " + pq.QuoteIdentifier(name))
+	return err
+}
+
+// Copy sends rows to a table within a transaction, until rows channel is
+// closed. It returns the number of copied rows. If copying fails, the
+// remaining rows are read and ignored, so senders are never blocked.
+func Copy(tx *sql.Tx, t Table, rows <-chan []string) (int, error) {
+	count, err := copyRows(tx, t, rows)
+	if err != nil {
+		drain(rows)
+	}
+	return count, err
+}
+
+// copyRows sends rows to a table until rows channel is closed or copying
+// fails. Rows left in the channel after a failure are not read.
+func copyRows(tx *sql.Tx, t Table, rows <-chan []string) (int, error) {
+	count := 0
+	stmt, err := tx.Prepare(pq.CopyIn(t.Name, t.Columns...))
+	if err != nil {
+		return count, err
+	}
+
+	notNull := make([]bool, len(t.Columns))
+	for i, c := range t.Columns {
+		for _, nn := range t.NotNull {
+			if c == nn {
+				notNull[i] = true
+			}
+		}
+	}
+
+	args := make([]interface{}, len(t.Columns))
+	for row := range rows {
+		if len(row) != len(t.Columns) {
+			stmt.Close()
+			return count, fmt.Errorf("%s: row %d has %d fields instead of %d",
+				t.Name, count+1, len(row), len(t.Columns))
+		}
+		for i, v := range row {
+			if v == "" && !notNull[i] {
+				args[i] = nil
+			} else {
+				args[i] = v
+			}
+		}
+		if _, err = stmt.Exec(args...); err != nil {
+			stmt.Close()
+			return count, err
+		}
+		count++
+		if count%progressStep == 0 {
+			log.Printf("%s: copied %d rows\n", t.Name, count)
+		}
+	}
+
+	if _, err = stmt.Exec(); err != nil {
+		stmt.Close()
+		return count, err
+	}
+	return count, stmt.Close()
+}
+
+func drain(rows <-chan []string) {
+	for range rows {
+	}
+}
+
+// Loader truncates a table and loads rows into it in its own transaction,
+// so several tables can be loaded in parallel.
+type Loader struct {
+	table Table
+	rows  chan []string
+	done  chan struct{}
+	start time.Time
+	count int
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewLoader starts a transaction for a table and truncates the table. Rows
+// given to the loader become visible after Close.
+func NewLoader(db *sql.DB, t Table) (*Loader, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if err = Truncate(tx, t.Name); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	l := &Loader{
+		table: t,
+		rows:  make(chan []string, 1000),
+		done:  make(chan struct{}),
+		start: time.Now(),
+	}
+	go l.load(tx)
+	return l, nil
+}
+
+func (l *Loader) load(tx *sql.Tx) {
+	defer close(l.done)
+	count, err := copyRows(tx, l.table, l.rows)
+	if err != nil {
+		// the error is recorded before the remaining rows are read, so
+		// following calls of Write fail
+		l.setErr(err)
+		drain(l.rows)
+		tx.Rollback()
+	} else if err = tx.Commit(); err != nil {
+		l.setErr(err)
+	}
+	l.mu.Lock()
+	l.count = count
+	l.mu.Unlock()
+}
+
+func (l *Loader) setErr(err error) {
+	l.mu.Lock()
+	l.err = err
+	l.mu.Unlock()
+}
+
+// Write sends a row to the table. It returns an error if loading failed
+// already, so rows are not produced for a table that cannot be loaded.
+func (l *Loader) Write(row []string) error {
+	l.mu.Lock()
+	err := l.err
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	l.rows <- row
+	return nil
+}
+
+// Close waits until all rows are loaded and commits the transaction.
+func (l *Loader) Close() error {
+	close(l.rows)
+	<-l.done
+	if l.err != nil {
+		return fmt.Errorf("%s: %s", l.table.Name, l.err)
+	}
+	log.Printf("%s: loaded %d rows in %s\n", l.table.Name, l.count,
+		time.Since(l.start).Round(time.Second))
+	return nil
+}
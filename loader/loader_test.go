@@ -0,0 +1,77 @@
+package loader
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+)
+
+func testDB(t *testing.T) *sql.DB {
+	if os.Getenv("GNINDEX_HOST") == "" {
+		t.Skip("GNINDEX_HOST is not set")
+	}
+	db := DB()
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS gnidump_loader_test
+		(id integer, name varchar, note varchar)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestLoader(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	defer db.Exec("DROP TABLE gnidump_loader_test")
+
+	tbl := Table{Name: "gnidump_loader_test",
+		Columns: []string{"id", "name", "note"}, NotNull: []string{"name"}}
+	for i := 0; i < 2; i++ {
+		l, err := NewLoader(db, tbl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range [][]string{{"1", "Aus", "a"}, {"2", "", ""}} {
+			if err = l.Write(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = l.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var count, nulls, empty int
+	err := db.QueryRow(`SELECT count(*), count(*) - count(note),
+		count(*) FILTER (WHERE name = '') FROM gnidump_loader_test`).
+		Scan(&count, &nulls, &empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || nulls != 1 || empty != 1 {
+		t.Errorf("got %d rows, %d nulls, %d empty names", count, nulls, empty)
+	}
+}
+
+func TestLoaderError(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	defer db.Exec("DROP TABLE gnidump_loader_test")
+
+	l, err := NewLoader(db, Table{Name: "gnidump_loader_test",
+		Columns: []string{"id", "name", "note"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Write([]string{"not a number", "Aus", "a"})
+	// the error reaches Write without waiting for Close
+	for i := 0; i < 1000000 && err == nil; i++ {
+		err = l.Write([]string{"2", "Bus", "b"})
+	}
+	if err == nil {
+		t.Error("Write does not return an error after a wrong row")
+	}
+	if err = l.Close(); err == nil {
+		t.Error("expected an error for a wrong row")
+	}
+}
@@ -0,0 +1,247 @@
+package loader
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimus/gnidump/util"
+)
+
+// Schema contains SQL scripts that prepare gnindex database for restoring
+// data.
+type Schema struct {
+	// AlterTables adds columns missing in older gnindex databases.
+	AlterTables string
+	// DeleteIndexes removes indexes and constraints before loading data.
+	DeleteIndexes string
+	// CreateIndexes recreates indexes and constraints after loading data.
+	CreateIndexes string
+}
+
+// notNull lists columns where empty strings are not converted to NULL.
+var notNull = map[string][]string{
+	"name_strings":       {"name"},
+	"vernacular_strings": {"name"},
+}
+
+// Restore replaces data in gnindex database with data from CSV files
+// created by `gnidump create`. Only the given tables are loaded, data_sources
+// table is always taken from gni dump. Indexes are removed, and all tables
+// are truncated and loaded in one transaction. After the transaction is
+// committed, indexes are recreated in parallel.
+func Restore(db *sql.DB, s Schema, tables []string) error {
+	files, err := restoreFiles(util.GnindexDir, util.GniDir, tables)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if err = AlterTables(db, s.AlterTables); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = restoreTables(tx, s, files); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	if err = createIndexes(db, s.CreateIndexes); err != nil {
+		return err
+	}
+	log.Printf("Restore is finished in %s\n",
+		time.Since(start).Round(time.Second))
+	return nil
+}
+
+// restoreFiles returns paths to CSV files of gnindex tables in dir, and the
+// path to data_sources.csv in gniDir. Names of the files without extension
+// are names of the tables. Other files in dir, like a copy of
+// data_sources.csv, are ignored.
+func restoreFiles(dir string, gniDir string, tables []string) ([]string,
+	error) {
+	files := []string{filepath.Join(gniDir, "data_sources.csv")}
+	names := append([]string(nil), tables...)
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "data_sources" {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name+".csv"))
+	}
+	for _, path := range files {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("cannot find %s, run `gnidump create` first",
+				path)
+		}
+	}
+	return files, nil
+}
+
+func restoreTables(tx *sql.Tx, s Schema, files []string) error {
+	log.Println("Removing indexes")
+	for _, q := range statements(s.DeleteIndexes) {
+		if _, err := tx.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range files {
+		name := strings.TrimSuffix(filepath.Base(path), ".csv")
+		start := time.Now()
+		if err := Truncate(tx, name); err != nil {
+			return err
+		}
+		count, err := copyFile(tx, path, name)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		log.Printf("%s: loaded %d rows in %s\n", name, count,
+			time.Since(start).Round(time.Second))
+	}
+	return nil
+}
+
+// copyFile loads a CSV file into a table. The first line of the file
+// contains names of the columns.
+func copyFile(tx *sql.Tx, path string, name string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err != nil {
+		return 0, err
+	}
+
+	rows := make(chan []string, 1000)
+	var readErr error
+	go func() {
+		defer close(rows)
+		for {
+			row, err := r.Read()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				readErr = err
+				return
+			}
+			rows <- row
+		}
+	}()
+
+	t := Table{Name: name, Columns: header, NotNull: notNull[name]}
+	count, err := Copy(tx, t, rows)
+	if err == nil {
+		err = readErr
+	}
+	return count, err
+}
+
+// createIndexes runs statements of a script concurrently, each in its own
+// connection.
+func createIndexes(db *sql.DB, script string) error {
+	log.Println("Creating indexes")
+	queries := make(chan string)
+	errs := make(chan error, util.WorkersNum())
+	var wg sync.WaitGroup
+	for i := 0; i < util.WorkersNum(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for q := range queries {
+				start := time.Now()
+				if _, err := db.Exec(q); err != nil {
+					errs <- fmt.Errorf("%s: %s", q, err)
+					continue
+				}
+				log.Printf("%s: %s\n", indexName(q),
+					time.Since(start).Round(time.Second))
+			}
+		}()
+	}
+
+	var err error
+	done := make(chan struct{})
+	go func() {
+		for e := range errs {
+			log.Println(e)
+			err = e
+		}
+		close(done)
+	}()
+
+	for _, q := range statements(script) {
+		queries <- q
+	}
+	close(queries)
+	wg.Wait()
+	close(errs)
+	<-done
+	return err
+}
+
+// indexName returns the name of an index or a constraint created by a
+// statement.
+func indexName(q string) string {
+	ws := strings.Fields(q)
+	for i, w := range ws {
+		if (w == "INDEX" || w == "CONSTRAINT") && i+1 < len(ws) {
+			return ws[i+1]
+		}
+	}
+	return q
+}
+
+// AlterTables runs a script that adds columns missing in older gnindex
+// databases.
+func AlterTables(db *sql.DB, script string) error {
+	log.Println("Adding missing columns")
+	return execScript(db, script)
+}
+
+func execScript(db *sql.DB, script string) error {
+	for _, q := range statements(script) {
+		if _, err := db.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statements splits an SQL script into separate statements. Comments are
+// removed.
+func statements(script string) []string {
+	var lines []string
+	for _, l := range strings.Split(script, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(l), "--") {
+			lines = append(lines, l)
+		}
+	}
+	var res []string
+	for _, q := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if q = strings.TrimSpace(q); q != "" {
+			res = append(res, q)
+		}
+	}
+	return res
+}
@@ -0,0 +1,68 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatements(t *testing.T) {
+	script := `--
+-- Name: data_sources; Type: CONSTRAINT
+--
+
+ALTER TABLE ONLY data_sources
+    ADD CONSTRAINT data_sources_pkey PRIMARY KEY (id);
+
+
+CREATE INDEX index__vsid ON vernacular_string_indices USING btree (vernacular_string_id);
+`
+	res := statements(script)
+	if len(res) != 2 {
+		t.Fatalf("got %d statements, want 2: %q", len(res), res)
+	}
+	names := []string{"data_sources_pkey", "index__vsid"}
+	for i, q := range res {
+		if n := indexName(q); n != names[i] {
+			t.Errorf("got name %s, want %s", n, names[i])
+		}
+	}
+}
+
+func TestRestoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	gniDir := t.TempDir()
+	for _, path := range []string{
+		filepath.Join(dir, "name_strings.csv"),
+		filepath.Join(dir, "name_strings__genus.csv"),
+		filepath.Join(dir, "data_sources.csv"),
+		filepath.Join(dir, "stray_report.csv"),
+		filepath.Join(gniDir, "data_sources.csv"),
+	} {
+		if err := os.WriteFile(path, []byte("id\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := restoreFiles(dir, gniDir,
+		[]string{"name_strings__genus", "name_strings"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(gniDir, "data_sources.csv"),
+		filepath.Join(dir, "name_strings.csv"),
+		filepath.Join(dir, "name_strings__genus.csv")}
+	if len(res) != len(want) {
+		t.Fatalf("got %v, want %v", res, want)
+	}
+	for i := range want {
+		if res[i] != want[i] {
+			t.Errorf("got %s, want %s", res[i], want[i])
+		}
+	}
+
+	_, err = restoreFiles(dir, gniDir, []string{"name_strings", "missing"})
+	if err == nil {
+		t.Error("no error for a missing table file")
+	}
+}
@@ -3,23 +3,43 @@
 package main
 
 import (
+	"bufio"
+	_ "embed"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/dimus/gnidump/converter"
 	"github.com/dimus/gnidump/creator"
 	"github.com/dimus/gnidump/dump"
+	"github.com/dimus/gnidump/loader"
+	"github.com/dimus/gnidump/util"
 )
 
 var githash = "n/a"
 var buildstamp = "n/a"
 
+// SQL scripts are embedded, so restore does not depend on scripts directory.
+var (
+	//go:embed scripts/sql/gnindex_alter_tables.sql
+	alterTablesSQL string
+	//go:embed scripts/sql/gnindex_delete_indexes.sql
+	deleteIndexesSQL string
+	//go:embed scripts/sql/gnindex_create_indexes.sql
+	createIndexesSQL string
+)
+
 func main() {
 	command := ""
 	if len(os.Args) > 1 {
 		command = os.Args[1]
 	}
-	dump.Prepare()
+	// parsing of separate names does not need any of gnidump directories
+	if command != "parse" {
+		dump.Prepare()
+	}
 	switch command {
 	case "version":
 		fmt.Printf(" Version: %s\n Build Time: %s\n\n",
@@ -27,16 +47,132 @@ func main() {
 	case "dump":
 		dump.Tables()
 	case "convert":
-		converter.Data()
+		convertCmd := flag.NewFlagSet("convert", flag.ExitOnError)
+		resume := convertCmd.Bool("resume", false,
+			"continue interrupted conversion without cleaning key-value store")
+		convertCmd.Parse(os.Args[2:])
+		converter.Data(*resume)
 	case "create":
-		creator.Tables()
+		createCmd := flag.NewFlagSet("create", flag.ExitOnError)
+		deterministic := createCmd.Bool("deterministic", false,
+			"sort rows of all files, so the same data gives the same files")
+		paths := createCmd.String("paths", "report", pathsHelp)
+		createCmd.Parse(os.Args[2:])
+		creator.Tables(*deterministic, pathMode(*paths))
+	case "load":
+		loadCmd := flag.NewFlagSet("load", flag.ExitOnError)
+		paths := loadCmd.String("paths", "report", pathsHelp)
+		loadCmd.Parse(os.Args[2:])
+		creator.Load(pathMode(*paths), alterTablesSQL)
+	case "canonicals":
+		canonicals(os.Args[2:])
+	case "diff":
+		diff(os.Args[2:])
+	case "restore":
+		restore(os.Args[2:])
+	case "parse":
+		parse(os.Args[2:])
 	default:
 		help := `
 Usage:
   gnidump dump
-	gnidump convert
-	gnidump create
+	gnidump convert [--resume]
+	gnidump create [--deterministic] [--paths report|drop|repair]
+	gnidump load [--paths report|drop|repair]
+	gnidump restore [--yes]
+	gnidump canonicals --out DIR
+	gnidump diff [--out DIR] OLD_DIR NEW_DIR
+	gnidump parse [--format json|csv] "Name"
+	gnidump parse [--format json|csv] -f names.txt
 `
 		fmt.Println(help)
 	}
 }
+
+func parse(args []string) {
+	parseCmd := flag.NewFlagSet("parse", flag.ExitOnError)
+	file := parseCmd.String("f", "", "file with names, one name per line")
+	format := parseCmd.String("format", "json", "output format: json or csv")
+	parseCmd.Parse(args)
+
+	names := parseCmd.Args()
+	if *file != "" {
+		var err error
+		names, err = converter.NamesFromFile(*file)
+		util.Check(err)
+	}
+	if len(names) == 0 {
+		fmt.Println("Give a name or a file with names to parse")
+		os.Exit(1)
+	}
+	err := converter.Parse(names, *format, os.Stdout)
+	util.Check(err)
+}
+
+const pathsHelp = "what to do with inconsistent classification paths: " +
+	"report, drop or repair"
+
+func pathMode(s string) creator.PathMode {
+	mode, err := creator.NewPathMode(s)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return mode
+}
+
+func canonicals(args []string) {
+	canonicalsCmd := flag.NewFlagSet("canonicals", flag.ExitOnError)
+	out := canonicalsCmd.String("out", "",
+		"directory for text files with canonical forms")
+	canonicalsCmd.Parse(args)
+
+	if *out == "" {
+		fmt.Println("Give a directory for canonical files with --out")
+		os.Exit(1)
+	}
+	err := os.MkdirAll(*out, 0755)
+	util.Check(err)
+	creator.Canonicals(*out)
+}
+
+func diff(args []string) {
+	diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
+	out := diffCmd.String("out", filepath.Join(util.ReportDir, "diff"),
+		"directory for CSV files with differences")
+	diffCmd.Parse(args)
+
+	if diffCmd.NArg() != 2 {
+		fmt.Println("Give directories with old and new gnindex files")
+		os.Exit(1)
+	}
+	err := os.MkdirAll(*out, 0755)
+	util.Check(err)
+	err = creator.Diff(diffCmd.Arg(0), diffCmd.Arg(1), *out, os.Stdout)
+	util.Check(err)
+}
+
+func restore(args []string) {
+	restoreCmd := flag.NewFlagSet("restore", flag.ExitOnError)
+	yes := restoreCmd.Bool("yes", false, "do not ask for confirmation")
+	restoreCmd.Parse(args)
+
+	if !*yes {
+		fmt.Printf("You are about to update DB on %s. Are you sure? (Y/y) ",
+			util.EnvVars()["pg_host"])
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if answer != "Y" && answer != "y" {
+			return
+		}
+	}
+
+	db := loader.DB()
+	defer db.Close()
+	err := loader.Restore(db, loader.Schema{
+		AlterTables:   alterTablesSQL,
+		DeleteIndexes: deleteIndexesSQL,
+		CreateIndexes: createIndexesSQL,
+	}, creator.TableNames())
+	util.Check(err)
+}
@@ -0,0 +1,82 @@
+--
+-- Columns and tables added to gnindex by gnidump. The script can run several
+-- times, existing columns and tables are not changed.
+--
+
+--
+-- Name: name_strings; Type: TABLE; Schema: public; Owner: postgres
+--
+
+ALTER TABLE name_strings
+    ADD COLUMN IF NOT EXISTS authorship character varying,
+    ADD COLUMN IF NOT EXISTS year character varying,
+    ADD COLUMN IF NOT EXISTS rank character varying,
+    ADD COLUMN IF NOT EXISTS quality integer,
+    ADD COLUMN IF NOT EXISTS hybrid boolean,
+    ADD COLUMN IF NOT EXISTS virus boolean,
+    ADD COLUMN IF NOT EXISTS canonical_stem character varying,
+    ADD COLUMN IF NOT EXISTS name_type character varying;
+
+--
+-- Name: name_string_indices; Type: TABLE; Schema: public; Owner: postgres
+--
+
+ALTER TABLE name_string_indices
+    ADD COLUMN IF NOT EXISTS accepted_chain_depth integer;
+
+--
+-- Name: vernacular_string_indices; Type: TABLE; Schema: public; Owner: postgres
+--
+
+ALTER TABLE vernacular_string_indices
+    ADD COLUMN IF NOT EXISTS language_orig character varying;
+
+--
+-- Name: name_strings__cultivar; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE IF NOT EXISTS name_strings__cultivar (
+    cultivar character varying NOT NULL,
+    name_uuid uuid NOT NULL
+);
+
+--
+-- Name: name_strings__hybrid_char; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE IF NOT EXISTS name_strings__hybrid_char (
+    hybrid_char character varying NOT NULL,
+    name_uuid uuid NOT NULL
+);
+
+--
+-- Name: name_strings__infragenus; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE IF NOT EXISTS name_strings__infragenus (
+    infragenus character varying NOT NULL,
+    name_uuid uuid NOT NULL
+);
+
+--
+-- Name: name_strings__rank; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE IF NOT EXISTS name_strings__rank (
+    rank character varying NOT NULL,
+    name_uuid uuid NOT NULL
+);
+
+--
+-- Name: name_strings__author_words; Type: TABLE; Schema: public; Owner: postgres
+--
+
+ALTER TABLE name_strings__author_words
+    ADD COLUMN IF NOT EXISTS author_word_raw character varying;
+
+--
+-- Name: name_strings__year; Type: TABLE; Schema: public; Owner: postgres
+--
+
+ALTER TABLE name_strings__year
+    ADD COLUMN IF NOT EXISTS approximate boolean;
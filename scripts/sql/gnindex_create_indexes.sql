@@ -30,6 +30,13 @@ ALTER TABLE ONLY vernacular_strings
 CREATE INDEX canonical_name_index ON name_strings USING btree (canonical text_pattern_ops);
 
 
+--
+-- Name: canonical_stem_index; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX canonical_stem_index ON name_strings USING btree (canonical_stem text_pattern_ops);
+
+
 --
 -- Name: index__cmdsid_clid; Type: INDEX; Schema: public; Owner: postgres
 --
@@ -93,6 +100,20 @@ CREATE INDEX index_name_strings__author_words_on_author_word ON name_strings__au
 CREATE INDEX index_name_strings__author_words_on_name_uuid ON name_strings__author_words USING btree (name_uuid);
 
 
+--
+-- Name: index_name_strings__cultivar_on_cultivar; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX index_name_strings__cultivar_on_cultivar ON name_strings__cultivar USING btree (cultivar);
+
+
+--
+-- Name: index_name_strings__cultivar_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX index_name_strings__cultivar_on_name_uuid ON name_strings__cultivar USING btree (name_uuid);
+
+
 --
 -- Name: index_name_strings__genus_on_genus; Type: INDEX; Schema: public; Owner: postgres
 --
@@ -107,6 +128,48 @@ CREATE INDEX index_name_strings__genus_on_genus ON name_strings__genus USING btr
 CREATE INDEX index_name_strings__genus_on_name_uuid ON name_strings__genus USING btree (name_uuid);
 
 
+--
+-- Name: index_name_strings__hybrid_char_on_hybrid_char; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX index_name_strings__hybrid_char_on_hybrid_char ON name_strings__hybrid_char USING btree (hybrid_char);
+
+
+--
+-- Name: index_name_strings__hybrid_char_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX index_name_strings__hybrid_char_on_name_uuid ON name_strings__hybrid_char USING btree (name_uuid);
+
+
+--
+-- Name: index_name_strings__infragenus_on_infragenus; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX index_name_strings__infragenus_on_infragenus ON name_strings__infragenus USING btree (infragenus);
+
+
+--
+-- Name: index_name_strings__infragenus_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX index_name_strings__infragenus_on_name_uuid ON name_strings__infragenus USING btree (name_uuid);
+
+
+--
+-- Name: index_name_strings__rank_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX index_name_strings__rank_on_name_uuid ON name_strings__rank USING btree (name_uuid);
+
+
+--
+-- Name: index_name_strings__rank_on_rank; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX index_name_strings__rank_on_rank ON name_strings__rank USING btree (rank);
+
+
 --
 -- Name: index_name_strings__species_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
 --
@@ -198,6 +261,13 @@ CREATE INDEX namestrings_name__gin_index ON name_strings USING gin (name gin_trg
 CREATE INDEX ns_author_words__gin_index ON name_strings__author_words USING gin (author_word gin_trgm_ops);
 
 
+--
+-- Name: ns_cultivar__gin_index; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX ns_cultivar__gin_index ON name_strings__cultivar USING gin (cultivar gin_trgm_ops);
+
+
 --
 -- Name: ns_genus__gin_index; Type: INDEX; Schema: public; Owner: postgres
 --
@@ -205,6 +275,20 @@ CREATE INDEX ns_author_words__gin_index ON name_strings__author_words USING gin
 CREATE INDEX ns_genus__gin_index ON name_strings__genus USING gin (genus gin_trgm_ops);
 
 
+--
+-- Name: ns_infragenus__gin_index; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX ns_infragenus__gin_index ON name_strings__infragenus USING gin (infragenus gin_trgm_ops);
+
+
+--
+-- Name: ns_rank__gin_index; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX ns_rank__gin_index ON name_strings__rank USING gin (rank gin_trgm_ops);
+
+
 --
 -- Name: ns_species__gin_index; Type: INDEX; Schema: public; Owner: postgres
 --
@@ -4,7 +4,7 @@
 --
 
 ALTER TABLE ONLY data_sources
-    DROP CONSTRAINT data_sources_pkey;
+    DROP CONSTRAINT IF EXISTS data_sources_pkey;
 
 
 --
@@ -12,7 +12,7 @@ ALTER TABLE ONLY data_sources
 --
 
 ALTER TABLE ONLY name_strings
-    DROP CONSTRAINT name_strings_pkey;
+    DROP CONSTRAINT IF EXISTS name_strings_pkey;
 
 
 --
@@ -20,224 +20,308 @@ ALTER TABLE ONLY name_strings
 --
 
 ALTER TABLE ONLY vernacular_strings
-    DROP CONSTRAINT vernacular_strings_pkey;
+    DROP CONSTRAINT IF EXISTS vernacular_strings_pkey;
 
 
 --
 -- Name: canonical_name_index; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX canonical_name_index;
+DROP INDEX IF EXISTS canonical_name_index;
+
+
+--
+-- Name: canonical_stem_index; Type: INDEX; Schema: public; Owner: postgres
+--
+
+DROP INDEX IF EXISTS canonical_stem_index;
 
 
 --
 -- Name: index__cmdsid_clid; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index__cmdsid_clid;
+DROP INDEX IF EXISTS index__cmdsid_clid;
 
 
 --
 -- Name: index__dsid_tid; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index__dsid_tid;
+DROP INDEX IF EXISTS index__dsid_tid;
 
 
 --
 -- Name: index__nsid_dsid_tid; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index__nsid_dsid_tid;
+DROP INDEX IF EXISTS index__nsid_dsid_tid;
 
 
 --
 -- Name: index__vsid; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index__vsid;
+DROP INDEX IF EXISTS index__vsid;
 
 
 --
 -- Name: index_name_string_indices_on_data_source_id; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_string_indices_on_data_source_id;
+DROP INDEX IF EXISTS index_name_string_indices_on_data_source_id;
 
 
 --
 -- Name: index_name_string_indices_on_data_source_id_and_taxon_id; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_string_indices_on_data_source_id_and_taxon_id;
+DROP INDEX IF EXISTS index_name_string_indices_on_data_source_id_and_taxon_id;
 
 
 --
 -- Name: index_name_string_indices_on_name_string_id; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_string_indices_on_name_string_id;
+DROP INDEX IF EXISTS index_name_string_indices_on_name_string_id;
 
 
 --
 -- Name: index_name_strings__author_words_on_author_word; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_strings__author_words_on_author_word;
+DROP INDEX IF EXISTS index_name_strings__author_words_on_author_word;
 
 
 --
 -- Name: index_name_strings__author_words_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_strings__author_words_on_name_uuid;
+DROP INDEX IF EXISTS index_name_strings__author_words_on_name_uuid;
+
+
+--
+-- Name: index_name_strings__cultivar_on_cultivar; Type: INDEX; Schema: public; Owner: postgres
+--
+
+DROP INDEX IF EXISTS index_name_strings__cultivar_on_cultivar;
+
+
+--
+-- Name: index_name_strings__cultivar_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
+--
+
+DROP INDEX IF EXISTS index_name_strings__cultivar_on_name_uuid;
 
 
 --
 -- Name: index_name_strings__genus_on_genus; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_strings__genus_on_genus;
+DROP INDEX IF EXISTS index_name_strings__genus_on_genus;
 
 
 --
 -- Name: index_name_strings__genus_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_strings__genus_on_name_uuid;
+DROP INDEX IF EXISTS index_name_strings__genus_on_name_uuid;
+
+
+--
+-- Name: index_name_strings__hybrid_char_on_hybrid_char; Type: INDEX; Schema: public; Owner: postgres
+--
+
+DROP INDEX IF EXISTS index_name_strings__hybrid_char_on_hybrid_char;
+
+
+--
+-- Name: index_name_strings__hybrid_char_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
+--
+
+DROP INDEX IF EXISTS index_name_strings__hybrid_char_on_name_uuid;
+
+
+--
+-- Name: index_name_strings__infragenus_on_infragenus; Type: INDEX; Schema: public; Owner: postgres
+--
+
+DROP INDEX IF EXISTS index_name_strings__infragenus_on_infragenus;
+
+
+--
+-- Name: index_name_strings__infragenus_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
+--
+
+DROP INDEX IF EXISTS index_name_strings__infragenus_on_name_uuid;
+
+
+--
+-- Name: index_name_strings__rank_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
+--
+
+DROP INDEX IF EXISTS index_name_strings__rank_on_name_uuid;
+
+
+--
+-- Name: index_name_strings__rank_on_rank; Type: INDEX; Schema: public; Owner: postgres
+--
+
+DROP INDEX IF EXISTS index_name_strings__rank_on_rank;
 
 
 --
 -- Name: index_name_strings__species_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_strings__species_on_name_uuid;
+DROP INDEX IF EXISTS index_name_strings__species_on_name_uuid;
 
 
 --
 -- Name: index_name_strings__species_on_species; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_strings__species_on_species;
+DROP INDEX IF EXISTS index_name_strings__species_on_species;
 
 
 --
 -- Name: index_name_strings__subspecies_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_strings__subspecies_on_name_uuid;
+DROP INDEX IF EXISTS index_name_strings__subspecies_on_name_uuid;
 
 
 --
 -- Name: index_name_strings__subspecies_on_subspecies; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_strings__subspecies_on_subspecies;
+DROP INDEX IF EXISTS index_name_strings__subspecies_on_subspecies;
 
 
 --
 -- Name: index_name_strings__uninomial_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_strings__uninomial_on_name_uuid;
+DROP INDEX IF EXISTS index_name_strings__uninomial_on_name_uuid;
 
 
 --
 -- Name: index_name_strings__uninomial_on_uninomial; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_strings__uninomial_on_uninomial;
+DROP INDEX IF EXISTS index_name_strings__uninomial_on_uninomial;
 
 
 --
 -- Name: index_name_strings__year_on_name_uuid; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_strings__year_on_name_uuid;
+DROP INDEX IF EXISTS index_name_strings__year_on_name_uuid;
 
 
 --
 -- Name: index_name_strings__year_on_year; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_strings__year_on_year;
+DROP INDEX IF EXISTS index_name_strings__year_on_year;
 
 
 --
 -- Name: index_name_strings_on_canonical_uuid; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX index_name_strings_on_canonical_uuid;
+DROP INDEX IF EXISTS index_name_strings_on_canonical_uuid;
 
 
 --
 -- Name: name_string_indices__datasource_taxonid; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX name_string_indices__datasource_taxonid;
+DROP INDEX IF EXISTS name_string_indices__datasource_taxonid;
 
 
 --
 -- Name: namestrings_canonical__gin_index; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX namestrings_canonical__gin_index;
+DROP INDEX IF EXISTS namestrings_canonical__gin_index;
 
 
 --
 -- Name: namestrings_name__gin_index; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX namestrings_name__gin_index;
+DROP INDEX IF EXISTS namestrings_name__gin_index;
 
 
 --
 -- Name: ns_author_words__gin_index; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX ns_author_words__gin_index;
+DROP INDEX IF EXISTS ns_author_words__gin_index;
+
+
+--
+-- Name: ns_cultivar__gin_index; Type: INDEX; Schema: public; Owner: postgres
+--
+
+DROP INDEX IF EXISTS ns_cultivar__gin_index;
 
 
 --
 -- Name: ns_genus__gin_index; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX ns_genus__gin_index;
+DROP INDEX IF EXISTS ns_genus__gin_index;
+
+
+--
+-- Name: ns_infragenus__gin_index; Type: INDEX; Schema: public; Owner: postgres
+--
+
+DROP INDEX IF EXISTS ns_infragenus__gin_index;
+
+
+--
+-- Name: ns_rank__gin_index; Type: INDEX; Schema: public; Owner: postgres
+--
+
+DROP INDEX IF EXISTS ns_rank__gin_index;
 
 
 --
 -- Name: ns_species__gin_index; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX ns_species__gin_index;
+DROP INDEX IF EXISTS ns_species__gin_index;
 
 
 --
 -- Name: ns_subspecies__gin_index; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX ns_subspecies__gin_index;
+DROP INDEX IF EXISTS ns_subspecies__gin_index;
 
 
 --
 -- Name: ns_uninomial__gin_index; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX ns_uninomial__gin_index;
+DROP INDEX IF EXISTS ns_uninomial__gin_index;
 
 
 --
 -- Name: ns_year__gin_index; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX ns_year__gin_index;
+DROP INDEX IF EXISTS ns_year__gin_index;
 
 
 --
 -- Name: unique_schema_migrations; Type: INDEX; Schema: public; Owner: postgres
 --
 
-DROP INDEX unique_schema_migrations;
+DROP INDEX IF EXISTS unique_schema_migrations;
 
 
 --
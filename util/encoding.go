@@ -0,0 +1,206 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"gitlab.com/gogna/gnparser/pb"
+)
+
+// Encoded ParsedName starts with a zero marker followed by a format version.
+// Gob never starts a stream with a zero byte, so records written by older
+// versions of gnidump with EncodeGob can still be recognized and read.
+// EncodingVersion changes every time new fields are added to the end of the
+// record, records of all previous versions can be decoded.
+const (
+	encodingMarker  byte = 0
+	EncodingVersion byte = 5
+)
+
+var errShortRecord = errors.New("encoded ParsedName is truncated")
+
+// Encode serializes ParsedName into a compact binary format. Strings and
+// integers are stored with variable length prefixes, fields are written in
+// the order of their declaration.
+func (pn ParsedName) Encode() []byte {
+	b := make([]byte, 0, 256)
+	b = append(b, encodingMarker, EncodingVersion)
+	b = appendString(b, pn.ID)
+	b = appendString(b, pn.IDCanonical)
+	b = appendString(b, pn.IDOriginal)
+	b = appendString(b, pn.Name)
+	b = appendString(b, pn.Canonical)
+	b = appendString(b, pn.CanonicalWithRank)
+	b = appendBool(b, pn.Surrogate)
+	b = appendUvarint(b, uint64(len(pn.Positions)))
+	for _, v := range pn.Positions {
+		b = appendString(b, v.Type)
+		b = appendUvarint(b, uint64(v.Start))
+		b = appendUvarint(b, uint64(v.End))
+	}
+	b = appendString(b, pn.ParserVersion)
+	// version 2
+	b = appendUvarint(b, uint64(pn.Quality))
+	b = appendUvarint(b, uint64(len(pn.Warnings)))
+	for _, v := range pn.Warnings {
+		b = appendString(b, v)
+	}
+	// name of NameType since version 5, name of gnparser type before
+	b = appendString(b, pn.NameType.String())
+	// version 3
+	b = appendString(b, pn.Authorship)
+	b = appendString(b, pn.Year)
+	b = appendString(b, pn.Rank)
+	b = appendBool(b, pn.Hybrid)
+	b = appendBool(b, pn.Virus)
+	// version 4
+	b = appendString(b, pn.CanonicalStem)
+	return b
+}
+
+// Decode deserializes ParsedName from data created by Encode or by
+// EncodeGob.
+func Decode(data []byte) (ParsedName, error) {
+	if len(data) < 2 || data[0] != encodingMarker {
+		return decodeGob(data)
+	}
+	version := data[1]
+	if version == 0 || version > EncodingVersion {
+		return ParsedName{},
+			fmt.Errorf("unknown ParsedName encoding version %d", version)
+	}
+
+	var pn ParsedName
+	d := decoder{data: data[2:]}
+	pn.ID = d.string()
+	pn.IDCanonical = d.string()
+	pn.IDOriginal = d.string()
+	pn.Name = d.string()
+	pn.Canonical = d.string()
+	pn.CanonicalWithRank = d.string()
+	pn.Surrogate = d.bool()
+	posNum := d.uvarint()
+	if d.err == nil && posNum > 0 {
+		if posNum > uint64(len(d.data)) {
+			return ParsedName{}, errShortRecord
+		}
+		pn.Positions = make([]*pb.Position, posNum)
+		for i := range pn.Positions {
+			pn.Positions[i] = &pb.Position{
+				Type:  d.string(),
+				Start: int32(d.uvarint()),
+				End:   int32(d.uvarint()),
+			}
+		}
+	}
+	pn.ParserVersion = d.string()
+	if version >= 2 {
+		pn.Quality = int(d.uvarint())
+		warnNum := d.uvarint()
+		if d.err == nil && warnNum > 0 {
+			if warnNum > uint64(len(d.data)) {
+				return ParsedName{}, errShortRecord
+			}
+			pn.Warnings = make([]string, warnNum)
+			for i := range pn.Warnings {
+				pn.Warnings[i] = d.string()
+			}
+		}
+		pn.NameType = nameTypeFromString(d.string(), version)
+	}
+	if version >= 3 {
+		pn.Authorship = d.string()
+		pn.Year = d.string()
+		pn.Rank = d.string()
+		pn.Hybrid = d.bool()
+		pn.Virus = d.bool()
+	}
+	if version >= 4 {
+		pn.CanonicalStem = d.string()
+	}
+	if d.err != nil {
+		return ParsedName{}, d.err
+	}
+	return pn, nil
+}
+
+func decodeGob(data []byte) (ParsedName, error) {
+	var pn ParsedName
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("cannot decode gob ParsedName: %v", r)
+			}
+		}()
+		pn = DecodeGob(*bytes.NewBuffer(data))
+	}()
+	return pn, err
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+// decoder reads fields written by Encode. After the first error all reads
+// return zero values, so the error is checked once at the end.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = errShortRecord
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	l := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if l > uint64(len(d.data)) {
+		d.err = errShortRecord
+		return ""
+	}
+	s := string(d.data[:l])
+	d.data = d.data[l:]
+	return s
+}
+
+func (d *decoder) bool() bool {
+	if d.err != nil {
+		return false
+	}
+	if len(d.data) == 0 {
+		d.err = errShortRecord
+		return false
+	}
+	v := d.data[0] == 1
+	d.data = d.data[1:]
+	return v
+}
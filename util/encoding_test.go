@@ -0,0 +1,124 @@
+package util
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"gitlab.com/gogna/gnparser/pb"
+)
+
+func testParsedName() ParsedName {
+	return ParsedName{
+		ID:                "16f235a0-e4a3-529c-9b83-bd15fe722110",
+		IDCanonical:       "16f235a0-e4a3-529c-9b83-bd15fe722110",
+		IDOriginal:        "12345",
+		Name:              "Homo sapiens Linnaeus, 1758",
+		Canonical:         "Homo sapiens",
+		CanonicalWithRank: "Homo sapiens",
+		Positions: []*pb.Position{
+			{Type: "genus", Start: 0, End: 4},
+			{Type: "specificEpithet", Start: 5, End: 12},
+			{Type: "authorWord", Start: 13, End: 21},
+			{Type: "year", Start: 23, End: 27},
+		},
+		ParserVersion: "v0.11.0-dev",
+		Quality:       1,
+		NameType:      Binomial,
+		Authorship:    "Linnaeus, 1758",
+		Year:          "1758",
+		CanonicalStem: "Homo sapiens",
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	pn := testParsedName()
+	res, err := Decode(pn.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pn, res) {
+		t.Errorf("got %+v, want %+v", res, pn)
+	}
+
+	empty, err := Decode(ParsedName{}.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(empty, ParsedName{}) {
+		t.Errorf("got %+v, want empty ParsedName", empty)
+	}
+}
+
+func TestDecodeGobRecord(t *testing.T) {
+	pn := testParsedName()
+	b := pn.EncodeGob()
+	res, err := Decode(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pn, res) {
+		t.Errorf("got %+v, want %+v", res, pn)
+	}
+}
+
+func TestDecodeVersion1(t *testing.T) {
+	pn := testParsedName()
+	pn.Quality, pn.Warnings, pn.NameType = 0, nil, NoParse
+	pn.Authorship, pn.Year, pn.CanonicalStem = "", "", ""
+	data := pn.Encode()
+	// remove fields added in versions 2 to 4, name type "no_parse" takes
+	// 9 bytes, other fields take 1 byte each
+	data = data[:len(data)-17]
+	data[1] = 1
+	res, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pn, res) {
+		t.Errorf("got %+v, want %+v", res, pn)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	data := testParsedName().Encode()
+	if _, err := Decode(data[:len(data)/2]); err == nil {
+		t.Error("expected an error for truncated record")
+	}
+	data[1] = EncodingVersion + 1
+	if _, err := Decode(data); err == nil {
+		t.Error("expected an error for unknown version")
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	pn := testParsedName()
+	for i := 0; i < b.N; i++ {
+		_ = pn.Encode()
+	}
+}
+
+func BenchmarkEncodeGob(b *testing.B) {
+	pn := testParsedName()
+	for i := 0; i < b.N; i++ {
+		_ = pn.EncodeGob()
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	data := testParsedName().Encode()
+	for i := 0; i < b.N; i++ {
+		_, err := Decode(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeGob(b *testing.B) {
+	buf := testParsedName().EncodeGob()
+	data := buf.Bytes()
+	for i := 0; i < b.N; i++ {
+		_ = DecodeGob(*bytes.NewBuffer(data))
+	}
+}
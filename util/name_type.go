@@ -0,0 +1,126 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+
+	"gitlab.com/gogna/gnparser/pb"
+)
+
+// NameType is the kind of a scientific name.
+type NameType int
+
+// Kinds of scientific names.
+const (
+	NoParse NameType = iota
+	Uninomial
+	Binomial
+	Infraspecific
+	Comparison
+	Approximation
+	Surrogate
+	BOLD
+	NamedHybrid
+	HybridFormula
+	Virus
+)
+
+var nameTypeStrings = []string{"no_parse", "uninomial", "binomial",
+	"infraspecific", "comparison", "approximation", "surrogate", "bold",
+	"named_hybrid", "hybrid_formula", "virus"}
+
+// NameTypes returns all kinds of scientific names.
+func NameTypes() []NameType {
+	res := make([]NameType, len(nameTypeStrings))
+	for i := range res {
+		res[i] = NameType(i)
+	}
+	return res
+}
+
+// String returns the name of a NameType, as it is written to gnindex.
+func (nt NameType) String() string {
+	if nt < 0 || int(nt) >= len(nameTypeStrings) {
+		return "unknown"
+	}
+	return nameTypeStrings[nt]
+}
+
+// IsSurrogate is true for names that are not formal scientific names,
+// including names with uncertain identification.
+func (nt NameType) IsSurrogate() bool {
+	return nt == Surrogate || nt == BOLD || nt == Approximation ||
+		nt == Comparison
+}
+
+// MarshalJSON represents NameType as its name.
+func (nt NameType) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(nt.String())), nil
+}
+
+// NewNameType classifies a name using results of gnparser.
+func NewNameType(p *pb.Parsed) NameType {
+	switch p.NameType {
+	case pb.NameType_UNINOMIAL:
+		return Uninomial
+	case pb.NameType_SPECIES:
+		if p.Cardinality > 2 {
+			return Infraspecific
+		}
+		return Binomial
+	case pb.NameType_COMPARISON:
+		return Comparison
+	case pb.NameType_APPROX_SURROGATE:
+		return Approximation
+	case pb.NameType_SURROGATE:
+		if strings.Contains(p.Verbatim, "BOLD:") {
+			return BOLD
+		}
+		switch p.Details.(type) {
+		case *pb.Parsed_Approximation:
+			return Approximation
+		case *pb.Parsed_Comparison:
+			return Comparison
+		}
+		return Surrogate
+	case pb.NameType_NAMED_HYBRID:
+		return NamedHybrid
+	case pb.NameType_HYBRID_FORMULA:
+		return HybridFormula
+	case pb.NameType_VIRUS:
+		return Virus
+	}
+	return NoParse
+}
+
+// nameTypeFromString returns NameType by its name. Records encoded before
+// version 5 keep names of gnparser types instead.
+func nameTypeFromString(s string, version byte) NameType {
+	if version < 5 {
+		switch s {
+		case "UNINOMIAL":
+			return Uninomial
+		case "SPECIES":
+			return Binomial
+		case "COMPARISON":
+			return Comparison
+		case "APPROX_SURROGATE":
+			return Approximation
+		case "SURROGATE":
+			return Surrogate
+		case "NAMED_HYBRID":
+			return NamedHybrid
+		case "HYBRID_FORMULA":
+			return HybridFormula
+		case "VIRUS":
+			return Virus
+		}
+		return NoParse
+	}
+	for i, v := range nameTypeStrings {
+		if v == s {
+			return NameType(i)
+		}
+	}
+	return NoParse
+}
@@ -0,0 +1,79 @@
+package util
+
+import (
+	"encoding/csv"
+	"os"
+	"sync"
+)
+
+// Report is a CSV file in ReportDir that collects rows from concurrent
+// workers.
+type Report struct {
+	mu    sync.Mutex
+	file  *os.File
+	w     *csv.Writer
+	count int
+}
+
+// NewReport creates a report file with a header. If keep is true and the
+// file exists, new rows are appended to it.
+func NewReport(name string, header []string, keep bool) *Report {
+	return newCSVFile(ReportDir+name+".csv", header, keep)
+}
+
+// NewQuarantine creates a file with a header in QuarantineDir for rows that
+// were rejected during export to gnindex.
+func NewQuarantine(name string, header []string) *Report {
+	return newCSVFile(QuarantineDir+name+".csv", header, false)
+}
+
+// NewReportFile creates a report file with a header at any path, for
+// example in a temporary directory.
+func NewReportFile(path string, header []string) *Report {
+	return newCSVFile(path, header, false)
+}
+
+func newCSVFile(path string, header []string, keep bool) *Report {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if keep {
+		if _, err := os.Stat(path); err == nil {
+			flags = os.O_WRONLY | os.O_APPEND
+			header = nil
+		}
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	Check(err)
+	r := &Report{file: f, w: csv.NewWriter(f)}
+	if header != nil {
+		err = r.w.Write(header)
+		Check(err)
+	}
+	return r
+}
+
+// Write adds a row to the report.
+func (r *Report) Write(row []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.w.Write(row)
+	Check(err)
+	r.count++
+}
+
+// Count returns the number of rows written to the report by Write.
+func (r *Report) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count
+}
+
+// Close saves the report file.
+func (r *Report) Close() {
+	r.w.Flush()
+	err := r.w.Error()
+	Check(err)
+	err = r.file.Sync()
+	Check(err)
+	err = r.file.Close()
+	Check(err)
+}
@@ -12,13 +12,53 @@ import (
 	"gitlab.com/gogna/gnparser/pb"
 )
 
-// BudgerDir is a direcotry to the badger key-value store.
+// BudgerDir is a direcotry to the badger key-value store. ParseCacheDir
+// keeps parsed names between runs of the converter. ReportDir contains
+// reports about problems found in gni data. QuarantineDir keeps rows of gni
+// data that were not exported to gnindex.
 const (
-	BadgerDir  = "/opt/gnidump/badger/"
-	GniDir     = "/opt/gnidump/gni_mysql/"
-	GnindexDir = "/opt/gnidump/gnindex_pg/"
+	BadgerDir     = "/opt/gnidump/badger/"
+	ParseCacheDir = "/opt/gnidump/parse_cache/"
+	GniDir        = "/opt/gnidump/gni_mysql/"
+	GnindexDir    = "/opt/gnidump/gnindex_pg/"
+	ReportDir     = "/opt/gnidump/reports/"
+	QuarantineDir = "/opt/gnidump/quarantine/"
 )
 
+// Key prefixes of the badger key-value store. Every parsed name is stored
+// once under its UUID, gni IDs of name-strings point to these UUIDs. gni IDs
+// of vernacular strings point to UUIDs of vernacular strings. Nomenclatural
+// codes of a name are kept as keys made of the name UUID and the code.
+const (
+	NamePrefix       = "n:"
+	OriginalPrefix   = "o:"
+	VernacularPrefix = "v:"
+	CodePrefix       = "c:"
+)
+
+// NameKey returns the key of a ParsedName record for a name-string UUID.
+func NameKey(uuid string) []byte {
+	return []byte(NamePrefix + uuid)
+}
+
+// OriginalKey returns the key that maps a gni name-string ID to a UUID.
+func OriginalKey(id string) []byte {
+	return []byte(OriginalPrefix + id)
+}
+
+// VernacularKey returns the key that maps a gni vernacular string ID to a
+// UUID.
+func VernacularKey(id string) []byte {
+	return []byte(VernacularPrefix + id)
+}
+
+// CodeKey returns the key that tells that a name-string UUID is used with a
+// nomenclatural code. If code is empty, it returns the prefix of all codes
+// of the name.
+func CodeKey(uuid string, code string) []byte {
+	return []byte(CodePrefix + uuid + "|" + code)
+}
+
 // ParsedName is a collection of all necessary information from the
 // scientific name parser.
 type ParsedName struct {
@@ -30,9 +70,20 @@ type ParsedName struct {
 	CanonicalWithRank string
 	Surrogate         bool
 	Positions         []*pb.Position
+	ParserVersion     string
+	Quality           int
+	Warnings          []string
+	NameType          NameType
+	Authorship        string
+	Year              string
+	Rank              string
+	Hybrid            bool
+	Virus             bool
+	CanonicalStem     string
 }
 
-// ParsedName.EncodeGob is a method for serlializing ParsedName value.
+// ParsedName.EncodeGob is a method for serlializing ParsedName value. It is
+// kept for comparison with Encode, which is used for storing ParsedName.
 func (pn ParsedName) EncodeGob() bytes.Buffer {
 	var b bytes.Buffer
 	enc := gob.NewEncoder(&b)
@@ -41,7 +92,8 @@ func (pn ParsedName) EncodeGob() bytes.Buffer {
 	return b
 }
 
-// DecodeGob deserializes bytes buffer to ParsedName struct.
+// DecodeGob deserializes bytes buffer to ParsedName struct. Use Decode to
+// read stored records, it recognizes both gob and current encoding.
 func DecodeGob(b bytes.Buffer) ParsedName {
 	var pn ParsedName
 	dec := gob.NewDecoder(&b)
@@ -76,6 +128,15 @@ func InitBadger() *badger.DB {
 	return bdb
 }
 
+// InitParseCache opens a badger key-value store with cached parsing results.
+// If the store does not exist, InitParseCache creates it.
+func InitParseCache() *badger.DB {
+	log.Println("Starting parse cache")
+	bdb, err := badger.Open(badger.DefaultOptions(ParseCacheDir))
+	Check(err)
+	return bdb
+}
+
 // EnvVars imports all environment variables relevant for the data conversion.
 func EnvVars() map[string]string {
 	env := make(map[string]string)
@@ -86,6 +147,15 @@ func EnvVars() map[string]string {
 	env["database"] = os.Getenv("DB_DATABASE")
 	env["workers"] = os.Getenv("WORKERS_NUMBER")
 	env["parser_url"] = os.Getenv("PARSER_URL")
+	env["nomenclatural_codes"] = os.Getenv("NOMENCLATURAL_CODES")
+	env["pg_host"] = os.Getenv("GNINDEX_HOST")
+	env["pg_port"] = os.Getenv("GNINDEX_PORT")
+	env["pg_user"] = os.Getenv("GNINDEX_USERNAME")
+	env["pg_password"] = os.Getenv("GNINDEX_PASSWORD")
+	env["pg_database"] = os.Getenv("GNINDEX_DATABASE")
+	if env["pg_database"] == "" {
+		env["pg_database"] = "gnindex"
+	}
 
 	return env
 }